@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 
 	"github.com/samber/oops"
 	"github.com/spf13/cobra"
@@ -32,6 +33,8 @@ func New(builder Builder, quitter Quitter) (Application, error) {
 	return &application{
 		cmd:     cmd,
 		quitter: quitter,
+		locker:  builder.SingleInstance,
+		daemon:  builder.Daemon,
 		chCmd:   make(chan error, 1),
 		chOut:   make(chan error, 1),
 		chSig:   make(chan os.Signal, 1),
@@ -43,12 +46,15 @@ type Application interface {
 }
 
 type application struct {
-	cmd     *cobra.Command
-	quitter Quitter
-	oops    oops.OopsErrorBuilder
-	chCmd   chan error
-	chOut   chan error
-	chSig   chan os.Signal
+	cmd      *cobra.Command
+	quitter  Quitter
+	locker   Locker
+	daemon   Daemon
+	stopping sync.Once
+	oops     oops.OopsErrorBuilder
+	chCmd    chan error
+	chOut    chan error
+	chSig    chan os.Signal
 }
 
 func (a *application) ExecuteContext(ctx context.Context) error {
@@ -63,6 +69,27 @@ func (a *application) ExecuteContext(ctx context.Context) error {
 	appCtx, appCancel := context.WithCancel(oopsCtx)
 	defer appCancel()
 
+	// Acquire the single-instance lock, losing the lock cancels the application context
+	if a.locker != nil {
+		var err error
+		var lockCtx context.Context
+		if lockCtx, err = a.locker.Lock(appCtx); err != nil {
+			slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelError, "failed to acquire single-instance lock", slog.Any("error", err))
+			return oops.FromContext(oopsCtx).Wrapf(err, "single-instance lock failed")
+		}
+		defer a.unlock(oopsCtx)
+		appCtx = lockCtx
+	}
+
+	// Write the PID file and notify the service manager when the application stops
+	if err := a.daemon.writePidFile(); err != nil {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelError, "failed to write pid file", slog.Any("error", err))
+		return oops.FromContext(oopsCtx).Wrapf(err, "pid file failed")
+	}
+	defer a.daemon.removePidFile(oopsCtx)
+	defer a.notifyStopping(oopsCtx)
+	go a.daemon.watchdog(appCtx)
+
 	// Run the application command using the signal context and output channel
 	go a.processOutput(oopsCtx, appCancel) // Process output using original context, as appCancel is called in processOutput, cancelling the context
 	go a.launch(appCtx)                    // Launch the Cobra command using the cancellable context
@@ -70,8 +97,29 @@ func (a *application) ExecuteContext(ctx context.Context) error {
 	return <-a.chOut
 }
 
+func (a *application) unlock(ctx context.Context) {
+	if err := a.locker.Unlock(ctx); err != nil {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "failed to release single-instance lock", slog.Any("error", err))
+	}
+}
+
+func (a *application) notifyStopping(ctx context.Context) {
+	a.stopping.Do(func() {
+		// The service keeps running in the process that took over
+		if handedOver.Load() {
+			return
+		}
+		a.daemon.notify(ctx, NotifyStopping)
+	})
+}
+
 func (a *application) launch(ctx context.Context) {
+	if !a.daemon.ManualReady {
+		a.daemon.notify(ctx, NotifyReady)
+	}
+
 	slogd.GetDefaultLogger().Log(ctx, slogd.LevelTrace, "starting cobra command")
+	metricStarts.Inc(a.cmd.Name())
 	a.chCmd <- a.cmd.ExecuteContext(ctx)
 }
 
@@ -102,6 +150,8 @@ func (a *application) processOutputWithSignals(ctx context.Context, appCancel co
 	select {
 	case sig := <-a.chSig: // sigCtx.Done() returns a channel that will have a message when the context is canceled.
 		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "received shutdown signal", slog.Any("signal", sig))
+		metricShutdownSignals.Inc(sig.String())
+		a.notifyStopping(ctx)
 
 		go a.handleShutdownSignal(shutdownCtx, chShutdown)
 		appCancel()
@@ -146,6 +196,7 @@ func (a *application) startGracefulShutdown(ctx context.Context) error {
 		return oops.FromContext(ctx).Wrap(err)
 	} else if err != nil && errors.Is(err, context.DeadlineExceeded) {
 		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "graceful shutdown deadline exceeded")
+		metricGracefulTimeouts.Inc()
 		return oops.FromContext(ctx).Wrap(err)
 	}
 	return nil
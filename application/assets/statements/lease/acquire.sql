@@ -0,0 +1,5 @@
+UPDATE application_lease
+SET holder     = ?,
+    expires_at = ?
+WHERE name = ?
+  AND (holder = ? OR expires_at < ?)
\ No newline at end of file
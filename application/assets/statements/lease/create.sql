@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS application_lease
+(
+    name       VARCHAR(255) NOT NULL PRIMARY KEY,
+    holder     VARCHAR(255) NOT NULL,
+    expires_at BIGINT       NOT NULL
+)
\ No newline at end of file
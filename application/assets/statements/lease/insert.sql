@@ -0,0 +1,2 @@
+INSERT INTO application_lease (name, holder, expires_at)
+VALUES (?, ?, ?)
\ No newline at end of file
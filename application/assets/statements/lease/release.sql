@@ -0,0 +1,4 @@
+DELETE
+FROM application_lease
+WHERE name = ?
+  AND holder = ?
\ No newline at end of file
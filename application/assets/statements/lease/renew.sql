@@ -0,0 +1,4 @@
+UPDATE application_lease
+SET expires_at = ?
+WHERE name = ?
+  AND holder = ?
\ No newline at end of file
@@ -26,6 +26,8 @@ type Builder struct {
 	TraverseRunHooks         bool
 	ValidArgs                []string
 	EnableVersionCommand     bool
+	SingleInstance           Locker // optional lock guaranteeing a single running instance of the application
+	Daemon                   Daemon // optional service manager integration
 }
 
 func (b Builder) applyBanner(cmd *cobra.Command) {
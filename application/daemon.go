@@ -0,0 +1,92 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+// Daemon configures the behaviour of the application when it runs as a service.
+type Daemon struct {
+	PidFile     string // path to the PID file, written at startup and removed at exit
+	Notify      bool   // send READY=1 and STOPPING=1 to the service manager through NOTIFY_SOCKET
+	ManualReady bool   // do not send READY=1 when the command launches, the command calls SdNotify(NotifyReady) itself
+	Watchdog    bool   // send WATCHDOG=1 at half the interval configured in WATCHDOG_USEC
+}
+
+func (d Daemon) notify(ctx context.Context, state string) {
+	if !d.Notify {
+		return
+	}
+
+	if sent, err := SdNotify(state); err != nil {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "failed to notify service manager", slog.String("state", state), slog.Any("error", err))
+	} else if sent {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "notified service manager", slog.String("state", state))
+	}
+}
+
+func (d Daemon) removePidFile(ctx context.Context) {
+	if d.PidFile == "" {
+		return
+	}
+
+	// Only remove the file if it still belongs to the current process
+	if contents, err := os.ReadFile(d.PidFile); err != nil || strings.TrimSpace(string(contents)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+
+	if err := os.Remove(d.PidFile); err != nil {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "failed to remove pid file", slog.String("path", d.PidFile), slog.Any("error", err))
+	}
+}
+
+// watchdog keeps the service manager watchdog alive until the context is cancelled.
+func (d Daemon) watchdog(ctx context.Context) {
+	if !d.Watchdog {
+		return
+	}
+
+	interval, err := WatchdogInterval()
+	if err != nil {
+		slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "watchdog disabled", slog.Any("error", err))
+		return
+	}
+
+	if interval == 0 {
+		return
+	}
+	slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "starting watchdog", slog.Duration("interval", interval))
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err = SdNotify(NotifyWatchdog); err != nil {
+				slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "failed to notify watchdog", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (d Daemon) writePidFile() error {
+	if d.PidFile == "" {
+		return nil
+	}
+
+	if err := os.WriteFile(d.PidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return oops.In("application").With("path", d.PidFile).Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package application
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+// newFakeNotifySocket creates a unix datagram socket and points NOTIFY_SOCKET to it.
+func newFakeNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to create notify socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv(envNotifySocket, path)
+	return conn
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	buf := make([]byte, 256)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	conn := newFakeNotifySocket(t)
+
+	tests := []string{NotifyReady, NotifyWatchdog, NotifyStopping}
+	for _, state := range tests {
+		t.Run(state, func(t *testing.T) {
+			sent, err := SdNotify(state)
+			if err != nil || !sent {
+				t.Fatalf("SdNotify() = %v, %v, want true, nil", sent, err)
+			}
+
+			if got := readNotification(t, conn); got != state {
+				t.Errorf("SdNotify() sent %q, want %q", got, state)
+			}
+		})
+	}
+}
+
+func TestSdNotify_NoSocket(t *testing.T) {
+	t.Setenv(envNotifySocket, "")
+
+	if sent, err := SdNotify(NotifyReady); sent || err != nil {
+		t.Errorf("SdNotify() = %v, %v, want false, nil", sent, err)
+	}
+}
+
+func TestNotifyMainPid(t *testing.T) {
+	slogd.All()
+	conn := newFakeNotifySocket(t)
+	t.Cleanup(func() { handedOver.Store(false) })
+
+	if err := NotifyMainPid(42); err != nil {
+		t.Fatalf("NotifyMainPid() error = %v", err)
+	}
+	if got, want := readNotification(t, conn), "MAINPID=42\n"+NotifyReady; got != want {
+		t.Errorf("NotifyMainPid() sent %q, want %q", got, want)
+	}
+
+	// The process that handed over does not report the service as stopping
+	a := &application{daemon: Daemon{Notify: true}}
+	a.notifyStopping(context.Background())
+
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := conn.Read(make([]byte, 256)); err == nil {
+		t.Errorf("notifyStopping() after NotifyMainPid() sent %d bytes, want none", n)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		usec    string
+		pid     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "disabled", usec: "", want: 0},
+		{name: "enabled", usec: "3000000", want: 3 * time.Second},
+		{name: "current pid", usec: "1000000", pid: strconv.Itoa(os.Getpid()), want: time.Second},
+		{name: "other pid", usec: "1000000", pid: "1", want: 0},
+		{name: "invalid", usec: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envWatchdogUsec, tt.usec)
+			t.Setenv(envWatchdogPid, tt.pid)
+
+			got, err := WatchdogInterval()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WatchdogInterval() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("WatchdogInterval() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_watchdog(t *testing.T) {
+	slogd.All()
+	conn := newFakeNotifySocket(t)
+	t.Setenv(envWatchdogUsec, "100000")
+	t.Setenv(envWatchdogPid, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Daemon{Watchdog: true}.watchdog(ctx)
+
+	if got := readNotification(t, conn); got != NotifyWatchdog {
+		t.Errorf("watchdog() sent %q, want %q", got, NotifyWatchdog)
+	}
+}
+
+func TestDaemon_PidFile(t *testing.T) {
+	slogd.All()
+	d := Daemon{PidFile: filepath.Join(t.TempDir(), "app.pid")}
+	if err := d.writePidFile(); err != nil {
+		t.Fatalf("writePidFile() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.removePidFile(ctx)
+
+	if _, err := os.Stat(d.PidFile); !os.IsNotExist(err) {
+		t.Errorf("removePidFile() did not remove %s", d.PidFile)
+	}
+}
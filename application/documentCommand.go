@@ -0,0 +1,42 @@
+package application
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/samber/oops"
+	"github.com/spf13/cobra"
+)
+
+// NewDocumentCommand returns a command writing the indented JSON encoding of the document to the file passed with --file, or to stdout,
+// e.g. the OpenAPI document of an httpd.Router created using httpd.NewOpenApi.
+func NewDocumentCommand(use string, short string, document any) Command {
+	return Command{
+		Command: &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				b, err := json.MarshalIndent(document, "", "  ")
+				if err != nil {
+					return oops.In("application").Wrap(err)
+				}
+				b = append(b, '\n')
+
+				file, _ := cmd.Flags().GetString("file")
+				if file == "" {
+					_, err = cmd.OutOrStdout().Write(b)
+					return err
+				}
+
+				if err = os.WriteFile(file, b, 0644); err != nil {
+					return oops.In("application").With("file", file).Wrap(err)
+				}
+				return nil
+			},
+		},
+		Configure: func(c *cobra.Command) {
+			c.Flags().StringP("file", "f", "", "File to write the document to, instead of stdout")
+		},
+	}
+}
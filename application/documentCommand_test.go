@@ -0,0 +1,46 @@
+package application
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewDocumentCommand(t *testing.T) {
+	document := map[string]any{"openapi": "3.1.0", "paths": map[string]any{}}
+	want := "{\n  \"openapi\": \"3.1.0\",\n  \"paths\": {}\n}\n"
+	file := filepath.Join(t.TempDir(), "openapi.json")
+
+	tests := []struct {
+		name string
+		args []string
+		file string
+	}{
+		{name: "stdout"},
+		{name: "file", args: []string{"--file", file}, file: file},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := NewDocumentCommand("openapi", "Write the OpenAPI document", document).Initialize(nil)
+			cmd.SetOut(&out)
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			got := out.String()
+			if tt.file != "" {
+				b, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatalf("Execute() did not write the document: %v", err)
+				}
+				got = string(b)
+			}
+			if got != want {
+				t.Errorf("Execute() wrote %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package application
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/samber/oops"
+)
+
+// NewFileLocker creates a Locker backed by an exclusive lock on a file on local disk.
+// The process ID of the lock holder is written to the file.
+// The file is left in place after unlocking, as removing it would allow two instances to hold a lock on different inodes.
+//
+// A process started with the locked file in the descriptor named by EnvLockFd takes over the lock of its parent, see LockFile.
+func NewFileLocker(path string) Locker {
+	return &fileLocker{
+		path: path,
+	}
+}
+
+type fileLocker struct {
+	path string
+	file *os.File
+	mux  sync.Mutex
+}
+
+func (l *fileLocker) Lock(ctx context.Context) (context.Context, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	oopsErr := oops.FromContext(ctx).With("path", l.path)
+	if l.file != nil {
+		return nil, oopsErr.New("lock already acquired")
+	}
+
+	var err error
+	var f *os.File
+	if f = l.inherited(); f == nil {
+		if f, err = os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+			return nil, oopsErr.Wrap(err)
+		}
+	}
+
+	if err = lockFile(f); err != nil {
+		var pid []byte
+		pid, _ = os.ReadFile(l.path)
+		_ = f.Close()
+		return nil, oopsErr.With("pid", string(pid)).Wrap(err)
+	}
+
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	if err != nil {
+		_ = unlockFile(f)
+		_ = f.Close()
+		return nil, oopsErr.Wrap(err)
+	}
+
+	l.file = f
+	return ctx, nil
+}
+
+func (l *fileLocker) Unlock(ctx context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	oopsErr := oops.FromContext(ctx).With("path", l.path)
+	defer func() {
+		_ = l.file.Close()
+		l.file = nil
+	}()
+
+	// A process that took over the lock wrote its own process ID, the lock is released when it closes the file
+	pid := make([]byte, 32)
+	n, _ := l.file.ReadAt(pid, 0)
+	if string(pid[:n]) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+
+	_ = l.file.Truncate(0)
+	if err := unlockFile(l.file); err != nil {
+		return oopsErr.Wrap(err)
+	}
+	return nil
+}
+
+// lockedFile returns the locked file, or nil if the lock is not held.
+func (l *fileLocker) lockedFile() *os.File {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.file
+}
+
+// inherited returns the lock file inherited from the parent process, or nil if it was not inherited or refers to another file.
+func (l *fileLocker) inherited() *os.File {
+	fd := os.Getenv(EnvLockFd)
+	if fd == "" {
+		return nil
+	}
+	_ = os.Unsetenv(EnvLockFd)
+
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 0 {
+		return nil
+	}
+
+	f := inheritLockFile(uintptr(n), l.path)
+	if f == nil {
+		return nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil
+	}
+	if pi, err := os.Stat(l.path); err != nil || !os.SameFile(fi, pi) {
+		_ = f.Close()
+		return nil
+	}
+	return f
+}
+
+// LockFile returns the locked file of a Locker created by NewFileLocker. Pass the file to a new process in the descriptor named by
+// EnvLockFd to let it take over the lock, e.g. using httpd.RegisterRestartFile, as the lock would otherwise prevent it from starting.
+// The lock is held while either process has the file open, and unlocking in the parent no longer releases it once the new process took over.
+func LockFile(l Locker) (*os.File, bool) {
+	fl, ok := l.(*fileLocker)
+	if !ok {
+		return nil, false
+	}
+
+	f := fl.lockedFile()
+	return f, f != nil
+}
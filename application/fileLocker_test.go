@@ -0,0 +1,41 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package application
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFileLocker_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	ctx := context.Background()
+
+	first := NewFileLocker(path)
+	if _, err := first.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v, wantErr false", err)
+	}
+
+	contents, _ := os.ReadFile(path)
+	if string(contents) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Lock() wrote pid %q, want %d", contents, os.Getpid())
+	}
+
+	second := NewFileLocker(path)
+	if _, err := second.Lock(ctx); !errors.Is(err, ErrLocked) {
+		t.Errorf("Lock() error = %v, want %v", err, ErrLocked)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v, wantErr false", err)
+	}
+
+	if _, err := second.Lock(ctx); err != nil {
+		t.Errorf("Lock() after Unlock() error = %v, wantErr false", err)
+	}
+	_ = second.Unlock(ctx)
+}
@@ -0,0 +1,34 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// EnvLockFd holds the file descriptor of a file lock inherited from the parent process, e.g. during a graceful restart.
+	EnvLockFd = "APPLICATION_LOCK_FD"
+)
+
+var (
+	ErrLocked   = errors.New("lock is held by another instance")
+	ErrLockLost = errors.New("lock has been lost")
+)
+
+// Locker guarantees that only a single instance of an application executes at the same time.
+// Lock returns a context derived from the supplied context, which is cancelled when the lock is lost.
+type Locker interface {
+	Lock(ctx context.Context) (context.Context, error)
+	Unlock(ctx context.Context) error
+}
+
+// lockHolder returns an identifier for the current process, which is unique across hosts.
+func lockHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
@@ -0,0 +1,11 @@
+package application
+
+import (
+	"github.com/jantytgat/go-kit/metrics"
+)
+
+var (
+	metricStarts           = metrics.Default().Counter("application_starts_total", "Total number of application command starts.", "command")
+	metricShutdownSignals  = metrics.Default().Counter("application_shutdown_signals_total", "Total number of shutdown signals received.", "signal")
+	metricGracefulTimeouts = metrics.Default().Counter("application_graceful_shutdown_timeouts_total", "Total number of graceful shutdowns that exceeded their deadline.")
+)
@@ -0,0 +1,86 @@
+package application
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+const (
+	NotifyReady     = "READY=1"
+	NotifyReloading = "RELOADING=1"
+	NotifyStopping  = "STOPPING=1"
+	NotifyWatchdog  = "WATCHDOG=1"
+
+	envNotifySocket = "NOTIFY_SOCKET"
+	envWatchdogUsec = "WATCHDOG_USEC"
+	envWatchdogPid  = "WATCHDOG_PID"
+)
+
+// handedOver is set once another process became the main process of the service, see NotifyMainPid.
+var handedOver atomic.Bool
+
+// SdNotify sends a state notification to the service manager over the unix datagram socket in NOTIFY_SOCKET.
+// It returns false if NOTIFY_SOCKET is not set, meaning the application is not running under a service manager.
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv(envNotifySocket)
+	if socket == "" {
+		return false, nil
+	}
+
+	// Socket paths starting with '@' refer to the Linux abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	oopsErr := oops.In("application").With("socket", socket).With("state", state)
+
+	var err error
+	var conn *net.UnixConn
+	if conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"}); err != nil {
+		return false, oopsErr.Wrap(err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, oopsErr.Wrap(err)
+	}
+	return true, nil
+}
+
+// NotifyMainPid tells the service manager that the process with the pid is the main process of the service and is ready,
+// e.g. the new process of a graceful restart started by httpd.Restart. STOPPING=1 is not sent when the application stops afterwards,
+// as the service keeps running in the new process.
+func NotifyMainPid(pid int) error {
+	if _, err := SdNotify("MAINPID=" + strconv.Itoa(pid) + "\n" + NotifyReady); err != nil {
+		return err
+	}
+	handedOver.Store(true)
+	return nil
+}
+
+// WatchdogInterval returns the watchdog timeout configured by the service manager through WATCHDOG_USEC.
+// It returns 0 if the watchdog is not enabled for the current process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv(envWatchdogUsec)
+	if usec == "" {
+		return 0, nil
+	}
+
+	// If WATCHDOG_PID is set, it must match the current process
+	if pid := os.Getenv(envWatchdogPid); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	var err error
+	var interval int64
+	if interval, err = strconv.ParseInt(usec, 10, 64); err != nil || interval <= 0 {
+		return 0, oops.In("application").With(envWatchdogUsec, usec).New("invalid watchdog interval")
+	}
+	return time.Duration(interval) * time.Microsecond, nil
+}
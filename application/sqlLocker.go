@@ -0,0 +1,202 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+	"github.com/jantytgat/go-kit/sqr"
+)
+
+const (
+	DefaultLeaseTTL = 30 * time.Second
+
+	leaseCollection  = "lease"
+	leaseCreate      = "create"
+	leaseAcquire     = "acquire"
+	leaseInsert      = "insert"
+	leaseRenew       = "renew"
+	leaseRelease     = "release"
+	leaseRootPath    = "assets/statements"
+	leaseMinimumTTL  = 3 * time.Second
+	leaseRenewalRate = 3
+)
+
+//go:embed assets/statements/lease/*.sql
+var leaseStatements embed.FS
+
+// NewSqlLocker creates a Locker backed by a lease in a database table.
+// The queries are looked up in the "lease" collection of the supplied repository, which must contain the create, acquire, insert,
+// renew and release queries. If the repository is nil, the embedded default queries using '?' placeholders are used.
+// The lease is renewed at a third of the ttl, and the lock context is cancelled as soon as a renewal fails.
+func NewSqlLocker(db *sql.DB, repository *sqr.Repository, name string, ttl time.Duration) Locker {
+	if ttl < leaseMinimumTTL {
+		ttl = leaseMinimumTTL
+	}
+
+	return &sqlLocker{
+		db:         db,
+		repository: repository,
+		name:       name,
+		holder:     lockHolder(),
+		ttl:        ttl,
+	}
+}
+
+type sqlLocker struct {
+	db         *sql.DB
+	repository *sqr.Repository
+	name       string
+	holder     string
+	ttl        time.Duration
+	cancel     context.CancelCauseFunc
+	done       chan struct{}
+	mux        sync.Mutex
+}
+
+func (l *sqlLocker) Lock(ctx context.Context) (context.Context, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	oopsErr := oops.FromContext(ctx).With("lease", l.name).With("holder", l.holder)
+	if l.db == nil {
+		return nil, oopsErr.New("db is nil")
+	}
+
+	if l.cancel != nil {
+		return nil, oopsErr.New("lock already acquired")
+	}
+
+	var err error
+	if l.repository == nil {
+		if l.repository, err = sqr.NewFromFs(leaseStatements, leaseRootPath); err != nil {
+			return nil, oopsErr.Wrap(err)
+		}
+	}
+
+	if _, err = l.exec(ctx, leaseCreate); err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+
+	if err = l.acquire(ctx); err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+	slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "lease acquired", slog.String("lease", l.name), slog.String("holder", l.holder))
+
+	lockCtx, lockCancel := context.WithCancelCause(ctx)
+	l.cancel = lockCancel
+	l.done = make(chan struct{})
+	go l.renew(lockCtx, lockCancel, l.done)
+
+	return lockCtx, nil
+}
+
+func (l *sqlLocker) Unlock(ctx context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.cancel == nil {
+		return nil
+	}
+
+	// Stop renewing the lease before releasing it
+	l.cancel(nil)
+	<-l.done
+	l.cancel = nil
+
+	if _, err := l.exec(ctx, leaseRelease, l.name, l.holder); err != nil {
+		return oops.FromContext(ctx).With("lease", l.name).With("holder", l.holder).Wrap(err)
+	}
+	slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "lease released", slog.String("lease", l.name), slog.String("holder", l.holder))
+	return nil
+}
+
+// acquire takes over the lease if it is held by the current holder or if it has expired.
+// If the lease does not exist yet, it is inserted. An insert violating the primary key means another instance holds the lease,
+// other errors are returned as is.
+func (l *sqlLocker) acquire(ctx context.Context) error {
+	now := time.Now()
+	expiresAt := now.Add(l.ttl).UnixMilli()
+
+	var err error
+	var affected int64
+	if affected, err = l.exec(ctx, leaseAcquire, l.holder, expiresAt, l.name, l.holder, now.UnixMilli()); err != nil {
+		return err
+	}
+
+	if affected == 1 {
+		return nil
+	}
+
+	if _, err = l.exec(ctx, leaseInsert, l.name, l.holder, expiresAt); err != nil {
+		if isConstraintViolation(err) {
+			return errors.Join(ErrLocked, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// isConstraintViolation reports if the error of a query is an integrity constraint violation, such as a duplicate primary key.
+// database/sql has no error type for it, so the SQLSTATE class is used for drivers exposing it, and the error message otherwise.
+func isConstraintViolation(err error) bool {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return strings.HasPrefix(stateErr.SQLState(), "23")
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique") || strings.Contains(msg, "duplicate") || strings.Contains(msg, "constraint")
+}
+
+// renew extends the lease until the context is cancelled.
+// If the lease cannot be extended, the context is cancelled with ErrLockLost.
+func (l *sqlLocker) renew(ctx context.Context, cancel context.CancelCauseFunc, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.ttl / leaseRenewalRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			affected, err := l.exec(ctx, leaseRenew, time.Now().Add(l.ttl).UnixMilli(), l.name, l.holder)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil || affected != 1 {
+				slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "lease renewal failed", slog.String("lease", l.name), slog.String("holder", l.holder), slog.Any("error", err))
+				cancel(ErrLockLost)
+				return
+			}
+			slogd.GetDefaultLogger().LogAttrs(ctx, slogd.LevelTrace, "lease renewed", slog.String("lease", l.name), slog.String("holder", l.holder))
+		}
+	}
+}
+
+// exec runs a query from the lease collection and returns the number of affected rows.
+func (l *sqlLocker) exec(ctx context.Context, queryName string, args ...any) (int64, error) {
+	var err error
+	var stmt *sql.Stmt
+	if stmt, err = l.repository.DbPrepareContext(ctx, l.db, leaseCollection, queryName); err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var res sql.Result
+	if res, err = stmt.ExecContext(ctx, args...); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,197 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/jantytgat/go-kit/slogd"
+	"github.com/jantytgat/go-kit/sqr"
+)
+
+func newTestDb(t *testing.T) *sql.DB {
+	t.Helper()
+	slogd.All()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lease.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+// newTestSqlLocker creates a sql locker with the holder, as all lockers in a process share the same holder.
+func newTestSqlLocker(db *sql.DB, holder string) *sqlLocker {
+	l := NewSqlLocker(db, nil, "app", leaseMinimumTTL).(*sqlLocker)
+	l.holder = holder
+	return l
+}
+
+func leaseHolder(t *testing.T, db *sql.DB) string {
+	t.Helper()
+
+	var holder string
+	err := db.QueryRow("SELECT holder FROM application_lease WHERE name = 'app'").Scan(&holder)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("failed to query lease: %v", err)
+	}
+	return holder
+}
+
+func TestSqlLocker_Lock(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+
+	first := newTestSqlLocker(db, "first")
+	if _, err := first.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v, wantErr false", err)
+	}
+	if holder := leaseHolder(t, db); holder != "first" {
+		t.Errorf("Lock() lease holder = %q, want first", holder)
+	}
+
+	if _, err := first.Lock(ctx); err == nil {
+		t.Error("Lock() twice error = nil, wantErr true")
+	}
+
+	second := newTestSqlLocker(db, "second")
+	if _, err := second.Lock(ctx); !errors.Is(err, ErrLocked) {
+		t.Errorf("Lock() error = %v, want %v", err, ErrLocked)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v, wantErr false", err)
+	}
+	if holder := leaseHolder(t, db); holder != "" {
+		t.Errorf("Unlock() lease holder = %q, want released lease", holder)
+	}
+
+	if _, err := second.Lock(ctx); err != nil {
+		t.Errorf("Lock() after Unlock() error = %v, wantErr false", err)
+	}
+	_ = second.Unlock(ctx)
+}
+
+func TestSqlLocker_Lock_insertFailed(t *testing.T) {
+	db := newTestDb(t)
+
+	// A failing insert is only reported as ErrLocked if another instance inserted the lease
+	statements := fstest.MapFS{}
+	for _, name := range []string{"acquire", "create", "insert", "release", "renew"} {
+		b, _ := leaseStatements.ReadFile(leaseRootPath + "/lease/" + name + ".sql")
+		statements["lease/"+name+".sql"] = &fstest.MapFile{Data: b}
+	}
+	statements["lease/insert.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO missing_lease (name, holder, expires_at) VALUES (?, ?, ?)")}
+
+	repository, err := sqr.NewFromFs(statements, ".")
+	if err != nil {
+		t.Fatalf("failed to load statements: %v", err)
+	}
+
+	l := NewSqlLocker(db, repository, "app", leaseMinimumTTL)
+	if _, err = l.Lock(context.Background()); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Lock() error = %v, want an error other than %v", err, ErrLocked)
+	}
+}
+
+func TestSqlLocker_Lock_sameHolder(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+
+	// A restarted instance takes over its own lease without waiting for it to expire
+	if _, err := db.Exec("CREATE TABLE application_lease (name VARCHAR(255) NOT NULL PRIMARY KEY, holder VARCHAR(255) NOT NULL, expires_at BIGINT NOT NULL)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO application_lease VALUES ('app', 'first', ?)", time.Now().Add(time.Hour).UnixMilli()); err != nil {
+		t.Fatalf("failed to insert lease: %v", err)
+	}
+
+	l := newTestSqlLocker(db, "first")
+	if _, err := l.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v, wantErr false", err)
+	}
+	_ = l.Unlock(ctx)
+}
+
+func TestSqlLocker_expired(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+
+	first := newTestSqlLocker(db, "first")
+	firstCtx, err := first.Lock(ctx)
+	if err != nil {
+		t.Fatalf("Lock() error = %v, wantErr false", err)
+	}
+	defer func() {
+		_ = first.Unlock(ctx)
+	}()
+
+	// Expire the lease, as if the first instance stopped renewing it
+	if _, err = db.Exec("UPDATE application_lease SET expires_at = ? WHERE name = 'app'", time.Now().Add(-time.Second).UnixMilli()); err != nil {
+		t.Fatalf("failed to expire lease: %v", err)
+	}
+
+	second := newTestSqlLocker(db, "second")
+	if _, err = second.Lock(ctx); err != nil {
+		t.Fatalf("Lock() of expired lease error = %v, wantErr false", err)
+	}
+	defer func() {
+		_ = second.Unlock(ctx)
+	}()
+
+	// The next renewal of the first instance fails, which cancels its lock context
+	select {
+	case <-firstCtx.Done():
+		if cause := context.Cause(firstCtx); !errors.Is(cause, ErrLockLost) {
+			t.Errorf("lock context cause = %v, want %v", cause, ErrLockLost)
+		}
+	case <-time.After(2 * leaseMinimumTTL / leaseRenewalRate):
+		t.Fatal("lock context was not cancelled after the lease was lost")
+	}
+
+	// Unlocking a lost lease must not release the lease of the new holder
+	if err = first.Unlock(ctx); err != nil {
+		t.Errorf("Unlock() error = %v, wantErr false", err)
+	}
+	if holder := leaseHolder(t, db); holder != "second" {
+		t.Errorf("lease holder = %q, want second", holder)
+	}
+}
+
+func TestSqlLocker_renew(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+
+	l := newTestSqlLocker(db, "first")
+	lockCtx, err := l.Lock(ctx)
+	if err != nil {
+		t.Fatalf("Lock() error = %v, wantErr false", err)
+	}
+	defer func() {
+		_ = l.Unlock(ctx)
+	}()
+
+	var before int64
+	_ = db.QueryRow("SELECT expires_at FROM application_lease WHERE name = 'app'").Scan(&before)
+
+	time.Sleep(leaseMinimumTTL/leaseRenewalRate + 200*time.Millisecond)
+
+	var after int64
+	_ = db.QueryRow("SELECT expires_at FROM application_lease WHERE name = 'app'").Scan(&after)
+	if after <= before {
+		t.Errorf("lease expires at %d after renewal, want later than %d", after, before)
+	}
+	if lockCtx.Err() != nil {
+		t.Errorf("lock context error = %v after renewal, want nil", lockCtx.Err())
+	}
+}
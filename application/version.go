@@ -47,6 +47,11 @@ type Version struct {
 	PreRelease string
 }
 
+// GetVersion returns the version information of the application, as set by the build variables.
+func GetVersion() Version {
+	return version
+}
+
 func (v Version) IsValid() bool {
 	return regexSemver.MatchString(v.Full)
 }
@@ -0,0 +1,193 @@
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	AccessLogStructured AccessLogFormat = iota
+	AccessLogCommon
+	AccessLogCombined
+
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+type AccessLogFormat int
+
+// AccessLogOption configures the AccessLog middleware.
+type AccessLogOption func(a *accessLog)
+
+// AccessLog logs every request with its method, path, protocol, status, bytes written, latency, remote address, user agent and request ID.
+// By default, records are written to the default flow of the slogd LogSet in the request context, at info level for 1xx-3xx responses,
+// warn level for 4xx responses and error level for 5xx responses. Requests whose handler panicked before writing a response are logged
+// with status 500.
+func AccessLog(opts ...AccessLogOption) Middleware {
+	a := &accessLog{
+		format: AccessLogStructured,
+		levels: map[int]slog.Level{
+			1: slogd.LevelInfo,
+			2: slogd.LevelInfo,
+			3: slogd.LevelInfo,
+			4: slogd.LevelWarn,
+			5: slogd.LevelError,
+		},
+		sampling: 1,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newResponseWriter(w)
+
+			completed := false
+			defer func() {
+				if !completed && rw.status == 0 {
+					// The handler panicked, the client receives a server error or an aborted response
+					rw.status = http.StatusInternalServerError
+				}
+				a.log(r, rw, start, time.Since(start))
+			}()
+
+			next.ServeHTTP(rw, r)
+			completed = true
+		})
+	}
+}
+
+type accessLog struct {
+	flow     string
+	format   AccessLogFormat
+	writer   io.Writer
+	writeMux sync.Mutex
+	levels   map[int]slog.Level
+	sampling uint64
+	count    atomic.Uint64
+}
+
+// WithAccessLogFlow writes the access log records to the named slogd flow.
+func WithAccessLogFlow(name string) AccessLogOption {
+	return func(a *accessLog) {
+		a.flow = name
+	}
+}
+
+// WithAccessLogFormat writes access log lines in the Common or Combined Log Format to w, instead of logging structured records.
+func WithAccessLogFormat(format AccessLogFormat, w io.Writer) AccessLogOption {
+	return func(a *accessLog) {
+		a.format = format
+		a.writer = w
+	}
+}
+
+// WithAccessLogLevel sets the log level for a status class, e.g. 2 for 2xx responses.
+func WithAccessLogLevel(statusClass int, level slog.Level) AccessLogOption {
+	return func(a *accessLog) {
+		a.levels[statusClass] = level
+	}
+}
+
+// WithAccessLogSampling only logs one out of every n successful (2xx) responses. Other responses are always logged.
+func WithAccessLogSampling(n uint64) AccessLogOption {
+	return func(a *accessLog) {
+		if n > 0 {
+			a.sampling = n
+		}
+	}
+}
+
+func (a *accessLog) log(r *http.Request, w *responseWriter, start time.Time, latency time.Duration) {
+	status := w.Status()
+	if status/100 == 2 && a.sampling > 1 && (a.count.Add(1)-1)%a.sampling != 0 {
+		return
+	}
+
+	if a.format != AccessLogStructured && a.writer != nil {
+		a.writeLine(r, w, start)
+		return
+	}
+
+	ctx := r.Context()
+	level, ok := a.levels[status/100]
+	if !ok {
+		level = slogd.LevelInfo
+	}
+
+	logger := flowLogger(ctx, a.flow)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("protocol", r.Proto),
+		slog.Int("status", status),
+		slog.Int64("bytes", w.bytes),
+		slog.Duration("latency", latency),
+		slog.String("remoteAddress", r.RemoteAddr),
+		slog.String("userAgent", r.UserAgent()),
+	}
+
+	// When the RequestID middleware runs before the access log, slogd adds the request ID from the context.
+	// Otherwise, use the ID it echoed in the response, or the one sent by the client.
+	if _, ok := RequestIDFromContext(ctx); !ok {
+		id := w.Header().Get(HeaderRequestID)
+		if id == "" {
+			id = r.Header.Get(HeaderRequestID)
+		}
+		if id != "" {
+			attrs = append(attrs, slog.String("requestId", id))
+		}
+	}
+
+	logger.LogAttrs(ctx, level, "http request", attrs...)
+}
+
+// writeLine writes the request in the Common or Combined Log Format.
+func (a *accessLog) writeLine(r *http.Request, w *responseWriter, start time.Time) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || host == "" {
+		host = r.RemoteAddr
+	}
+
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	size := "-"
+	if w.bytes > 0 {
+		size = strconv.FormatInt(w.bytes, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s", clfValue(host), clfValue(user), start.Format(clfTimeFormat), r.Method+" "+r.URL.RequestURI()+" "+r.Proto, w.Status(), size)
+	if a.format == AccessLogCombined {
+		line += fmt.Sprintf(" %q %q", clfValue(r.Referer()), clfValue(r.UserAgent()))
+	}
+
+	a.writeMux.Lock()
+	defer a.writeMux.Unlock()
+
+	_, _ = io.WriteString(a.writer, line+"\n")
+}
+
+func clfValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+// newTestFlow registers a flow writing JSON records at trace level to the returned buffer.
+func newTestFlow(name string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	slogd.All().WithFlow(slogd.NewFlow(name, slogd.FlowFanOut).
+		WithHandler("json", slogd.NewDefaultJsonHandler("json", buf, slogd.LevelTrace, false)))
+	return buf
+}
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		record := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("body"))
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		level     string
+		requestId string
+	}{
+		{name: "ok", status: http.StatusOK, level: "INFO"},
+		{name: "request id", status: http.StatusOK, level: "INFO", requestId: "abc"},
+		{name: "not found", status: http.StatusNotFound, level: "WARN"},
+		{name: "server error", status: http.StatusInternalServerError, level: "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestFlow("access-" + tt.name)
+			h := AccessLog(WithAccessLogFlow("access-" + tt.name))(statusHandler(tt.status))
+
+			r := httptest.NewRequest(http.MethodGet, "/path?query=1", nil)
+			r.Header.Set("User-Agent", "test-agent")
+			if tt.requestId != "" {
+				r.Header.Set(HeaderRequestID, tt.requestId)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			records := decodeRecords(t, buf)
+			if len(records) != 1 {
+				t.Fatalf("AccessLog() wrote %d records, want 1", len(records))
+			}
+
+			record := records[0]
+			if record["level"] != tt.level {
+				t.Errorf("AccessLog() level = %v, want %v", record["level"], tt.level)
+			}
+			if record["status"] != float64(tt.status) || record["bytes"] != float64(4) || record["path"] != "/path" || record["method"] != http.MethodGet || record["protocol"] != "HTTP/1.1" || record["userAgent"] != "test-agent" {
+				t.Errorf("AccessLog() record = %v", record)
+			}
+			if id, ok := record["requestId"]; ok != (tt.requestId != "") || (ok && id != tt.requestId) {
+				t.Errorf("AccessLog() requestId = %v, want %q", id, tt.requestId)
+			}
+		})
+	}
+}
+
+func TestAccessLog_panic(t *testing.T) {
+	buf := newTestFlow("access-panic")
+	h := AccessLog(WithAccessLogFlow("access-panic"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("AccessLog() did not pass on the panic")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	records := decodeRecords(t, buf)
+	if len(records) != 1 || records[0]["status"] != float64(http.StatusInternalServerError) || records[0]["level"] != "ERROR" {
+		t.Errorf("AccessLog() records = %v, want a server error", records)
+	}
+}
+
+func TestAccessLog_Sampling(t *testing.T) {
+	buf := newTestFlow("access-sampling")
+	ok := AccessLog(WithAccessLogFlow("access-sampling"), WithAccessLogSampling(3))(statusHandler(http.StatusOK))
+	failed := AccessLog(WithAccessLogFlow("access-sampling"), WithAccessLogSampling(3))(statusHandler(http.StatusBadRequest))
+
+	for i := 0; i < 6; i++ {
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		failed.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if records := decodeRecords(t, buf); len(records) != 8 {
+		t.Errorf("AccessLog() wrote %d records, want 8", len(records))
+	}
+}
+
+func TestAccessLog_Format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format AccessLogFormat
+		want   *regexp.Regexp
+	}{
+		{
+			name:   "common",
+			format: AccessLogCommon,
+			want:   regexp.MustCompile(`^192\.0\.2\.1 - alice \[[^]]+] "GET /path\?query=1 HTTP/1\.1" 201 4\n$`),
+		},
+		{
+			name:   "combined",
+			format: AccessLogCombined,
+			want:   regexp.MustCompile(`^192\.0\.2\.1 - alice \[[^]]+] "GET /path\?query=1 HTTP/1\.1" 201 4 "-" "test-agent"\n$`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := AccessLog(WithAccessLogFormat(tt.format, buf))(statusHandler(http.StatusCreated))
+
+			r := httptest.NewRequest(http.MethodGet, "/path?query=1", nil)
+			r.SetBasicAuth("alice", "secret")
+			r.Header.Set("User-Agent", "test-agent")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !tt.want.MatchString(buf.String()) {
+				t.Errorf("AccessLog() = %q, want match for %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
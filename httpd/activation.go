@@ -0,0 +1,233 @@
+package httpd
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samber/oops"
+)
+
+const (
+	envListenPid     = "LISTEN_PID"
+	envListenFds     = "LISTEN_FDS"
+	envListenFdNames = "LISTEN_FDNAMES"
+	listenFdsStart   = 3
+)
+
+var (
+	// listenerNameEscaper escapes the separator of LISTEN_FDNAMES in the listener names passed on by a graceful restart
+	listenerNameEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+	inherited     []*inheritedListener
+	inheritedErr  error
+	inheritedOnce sync.Once
+	inheritedMux  sync.Mutex
+)
+
+type inheritedListener struct {
+	name      string
+	listener  net.Listener
+	claimed   bool
+	restarted bool
+}
+
+// InheritedListeners returns all listeners passed to the process using the LISTEN_FDS/LISTEN_PID/LISTEN_FDNAMES protocol,
+// as used by systemd socket activation. The environment variables are unset after reading, so they are not passed on to child processes.
+func InheritedListeners() ([]net.Listener, error) {
+	loadInheritedListeners()
+
+	inheritedMux.Lock()
+	defer inheritedMux.Unlock()
+
+	listeners := make([]net.Listener, 0, len(inherited))
+	for _, l := range inherited {
+		listeners = append(listeners, l.listener)
+	}
+	return listeners, inheritedErr
+}
+
+// InheritedListener claims the inherited listener with the supplied name from LISTEN_FDNAMES.
+// It returns false if no unclaimed listener with that name was passed to the process.
+func InheritedListener(name string) (net.Listener, bool) {
+	loadInheritedListeners()
+
+	inheritedMux.Lock()
+	defer inheritedMux.Unlock()
+
+	for _, l := range inherited {
+		if !l.claimed && l.name == name {
+			l.claim()
+			return l.listener, true
+		}
+	}
+	return nil, false
+}
+
+// claim marks the listener as used by a server.
+// When all inherited listeners are claimed, the parent process of a graceful restart is notified that the process is ready.
+func (l *inheritedListener) claim() {
+	l.claimed = true
+
+	// Unix sockets handed over by a graceful restart are owned by this process now, so they are removed when the listener is closed.
+	// Sockets passed by systemd are left alone, as they are owned by the socket unit.
+	if ul, ok := l.listener.(*net.UnixListener); ok && l.restarted {
+		ul.SetUnlinkOnClose(true)
+	}
+
+	for _, i := range inherited {
+		if !i.claimed {
+			return
+		}
+	}
+	_ = NotifyRestartReady()
+}
+
+// claimInheritedListener claims the first unclaimed inherited listener whose name or address matches the supplied address.
+func claimInheritedListener(network, address string) (net.Listener, bool) {
+	loadInheritedListeners()
+
+	inheritedMux.Lock()
+	defer inheritedMux.Unlock()
+
+	for _, l := range inherited {
+		if l.claimed {
+			continue
+		}
+
+		if l.name == address || addressMatches(l.listener.Addr(), network, address) {
+			l.claim()
+			return l.listener, true
+		}
+	}
+	return nil, false
+}
+
+// addressMatches reports if the listener address serves the requested address.
+// An empty or unspecified host in the requested address matches any host on the same port.
+func addressMatches(addr net.Addr, network, address string) bool {
+	if !strings.HasPrefix(addr.Network(), network) && !strings.HasPrefix(network, addr.Network()) {
+		return false
+	}
+
+	if addr.String() == address {
+		return true
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port != strconv.Itoa(tcpAddr.Port) {
+		return false
+	}
+
+	if host == "" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.Equal(tcpAddr.IP) || (ip.IsUnspecified() && tcpAddr.IP.IsUnspecified()))
+}
+
+// inheritedListenerName returns the name the listener was inherited with, or an empty string if it was not inherited.
+func inheritedListenerName(l net.Listener) string {
+	inheritedMux.Lock()
+	defer inheritedMux.Unlock()
+
+	for _, i := range inherited {
+		if i.listener == l {
+			return i.name
+		}
+	}
+	return ""
+}
+
+// listen returns the inherited listener for the address if it was passed to the process, otherwise it binds a new listener.
+func listen(ctx context.Context, network, address string) (net.Listener, error) {
+	if l, ok := claimInheritedListener(network, address); ok {
+		return l, nil
+	}
+
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	config := new(net.ListenConfig)
+	return config.Listen(ctx, network, address)
+}
+
+func loadInheritedListeners() {
+	inheritedOnce.Do(func() {
+		inheritedMux.Lock()
+		defer inheritedMux.Unlock()
+
+		inherited, inheritedErr = listenersFromEnv()
+	})
+}
+
+func listenersFromEnv() ([]*inheritedListener, error) {
+	pid := os.Getenv(envListenPid)
+	fds := os.Getenv(envListenFds)
+	names := os.Getenv(envListenFdNames)
+
+	if fds == "" {
+		return nil, nil
+	}
+
+	// A process started by a graceful restart cannot know its own pid in advance, so it identifies its parent instead
+	if pid != strconv.Itoa(os.Getpid()) && (pid != "" || os.Getenv(envRestartParentPid) != strconv.Itoa(os.Getppid())) {
+		return nil, nil
+	}
+
+	restarted := pid == "" && os.Getenv(envRestartParentPid) != ""
+
+	// Make sure the listeners are not inherited by child processes
+	_ = os.Unsetenv(envListenPid)
+	_ = os.Unsetenv(envListenFds)
+	_ = os.Unsetenv(envListenFdNames)
+	_ = os.Unsetenv(envRestartParentPid)
+
+	oopsErr := oops.In("httpd").With(envListenFds, fds).With(envListenFdNames, names)
+
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, oopsErr.New("invalid number of inherited file descriptors")
+	}
+
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+
+	var listeners []*inheritedListener
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+			if unescaped, uErr := url.PathUnescape(name); restarted && uErr == nil {
+				name = unescaped
+			}
+		}
+
+		// net.FileListener duplicates the file descriptor, so the original can be closed
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		l, lErr := net.FileListener(f)
+		_ = f.Close()
+		if lErr != nil {
+			err = oopsErr.With("fd", listenFdsStart+i).With("name", name).Wrap(lErr)
+			continue
+		}
+
+		listeners = append(listeners, &inheritedListener{name: name, listener: l, restarted: restarted})
+	}
+	return listeners, err
+}
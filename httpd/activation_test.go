@@ -0,0 +1,104 @@
+package httpd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const envActivationHelper = "HTTPD_TEST_ACTIVATION_HELPER"
+
+// TestActivationHelperProcess is not a real test, it runs the http server in a child process started by TestInheritedListeners.
+func TestActivationHelperProcess(t *testing.T) {
+	address := os.Getenv(envActivationHelper)
+	if address == "" {
+		return
+	}
+
+	// The service manager sets LISTEN_PID after forking, the child process simulates this
+	_ = os.Setenv(envListenPid, strconv.Itoa(os.Getpid()))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "inherited:%d", os.Getpid())
+	})
+
+	host, port, _ := net.SplitHostPort(address)
+	p, _ := strconv.Atoi(port)
+	_ = RunHttpServer(context.Background(), slogd.All().DefaultLogger(), host, p, mux, time.Second)
+	os.Exit(0)
+}
+
+func TestInheritedListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("failed to get listener file: %v", err)
+	}
+	address := l.Addr().String()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestActivationHelperProcess$")
+	cmd.Env = append(os.Environ(), envActivationHelper+"="+address, envListenFds+"=1", envListenFdNames+"=http")
+	cmd.ExtraFiles = []*os.File{f}
+	if err = cmd.Start(); err != nil {
+		t.Fatalf("failed to start child process: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	// Close the listener in the parent, only the child process can accept connections now
+	_ = f.Close()
+	_ = l.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + address)
+	if err != nil {
+		t.Fatalf("request to inherited listener failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if want := fmt.Sprintf("inherited:%d", cmd.Process.Pid); string(body) != want {
+		t.Errorf("response = %q, want %q", body, want)
+	}
+}
+
+func Test_addressMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    net.Addr
+		network string
+		address string
+		want    bool
+	}{
+		{name: "exact", addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, network: "tcp", address: "127.0.0.1:8080", want: true},
+		{name: "empty host", addr: &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, network: "tcp", address: ":8080", want: true},
+		{name: "unspecified host", addr: &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, network: "tcp", address: "0.0.0.0:8080", want: true},
+		{name: "other port", addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, network: "tcp", address: "127.0.0.1:8081", want: false},
+		{name: "other host", addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, network: "tcp", address: "10.0.0.1:8080", want: false},
+		{name: "unix", addr: &net.UnixAddr{Name: "/run/app.sock", Net: "unix"}, network: "unix", address: "/run/app.sock", want: true},
+		{name: "network mismatch", addr: &net.UnixAddr{Name: "/run/app.sock", Net: "unix"}, network: "tcp", address: "/run/app.sock", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addressMatches(tt.addr, tt.network, tt.address); got != tt.want {
+				t.Errorf("addressMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
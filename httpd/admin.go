@@ -0,0 +1,173 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+var (
+	startTime = time.Now()
+)
+
+// AdminOption configures the Admin handler.
+type AdminOption func(a *admin)
+
+type admin struct {
+	version any
+}
+
+// WithAdminVersion sets the version information served at /version, e.g. application.GetVersion().
+func WithAdminVersion(version any) AdminOption {
+	return func(a *admin) {
+		a.version = version
+	}
+}
+
+// Admin returns a handler to operate a running application. It must only be served on a protected listener, such as a unix socket
+// served by RunAdminServer, as it exposes profiling data and allows changing log levels. Panics are not recovered by the handler,
+// wrap it using Recover when serving it on a server that does not recover them, unlike RunSocketHttpServer:
+//
+//	GET /debug/pprof/            pprof profiles
+//	GET /version                 version set using WithAdminVersion, Go runtime version and start time
+//	GET /goroutines              stack traces of all goroutines
+//	GET /flows                   slogd flows with the level of their handlers
+//	GET /flows/{name}            a single slogd flow
+//	PUT /flows/{name}/level      set the level of all handlers of a flow, e.g. {"level": "TRACE"}
+func Admin(opts ...AdminOption) http.Handler {
+	a := &admin{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	rt := NewRouter()
+
+	rt.Handle(http.MethodGet, "/debug/pprof/", http.HandlerFunc(pprof.Index))
+	rt.Handle(http.MethodGet, "/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	rt.Handle(http.MethodGet, "/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	rt.Handle(http.MethodGet, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	rt.Handle(http.MethodPost, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	rt.Handle(http.MethodGet, "/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+
+	rt.Get("/version", a.serveVersion)
+	rt.Get("/goroutines", adminGoroutines)
+	rt.Get("/flows", adminFlows)
+	rt.Get("/flows/{name}", adminFlow)
+	rt.Put("/flows/{name}/level", adminSetFlowLevel)
+	return rt
+}
+
+// RunAdminServer serves the Admin handler on the unix socket until the context is cancelled.
+func RunAdminServer(ctx context.Context, log *slog.Logger, socketPath string, opts ...AdminOption) error {
+	return RunSocketHttpServer(ctx, log, socketPath, Admin(opts...), DefaultShutdownTimeout)
+}
+
+type adminFlowInfo struct {
+	Name     string             `json:"name"`
+	Default  bool               `json:"default"`
+	Handlers []adminHandlerInfo `json:"handlers"`
+}
+
+type adminHandlerInfo struct {
+	Name  string `json:"name"`
+	Level string `json:"level,omitempty"`
+}
+
+func newAdminFlowInfo(flow *slogd.Flow, defaultFlow string) adminFlowInfo {
+	info := adminFlowInfo{
+		Name:     flow.Name(),
+		Default:  flow.Name() == defaultFlow,
+		Handlers: make([]adminHandlerInfo, 0),
+	}
+
+	for _, h := range flow.Handlers() {
+		handler := adminHandlerInfo{Name: h.Name()}
+		if level, ok := h.Level(); ok {
+			handler.Level = slogd.GetLevelName(level)
+		}
+		info.Handlers = append(info.Handlers, handler)
+	}
+	return info
+}
+
+func adminFlow(w http.ResponseWriter, r *http.Request) error {
+	logSet := slogd.FromContext(r.Context())
+
+	flow, ok := logSet.Flow(r.PathValue("name"))
+	if !ok {
+		return oops.In("httpd").With(StatusKey, http.StatusNotFound).Public("flow not found").New("flow not found")
+	}
+	return writeJson(w, http.StatusOK, newAdminFlowInfo(flow, logSet.DefaultFlowName()))
+}
+
+func adminFlows(w http.ResponseWriter, r *http.Request) error {
+	logSet := slogd.FromContext(r.Context())
+
+	flows := make([]adminFlowInfo, 0)
+	for _, flow := range logSet.Flows() {
+		flows = append(flows, newAdminFlowInfo(flow, logSet.DefaultFlowName()))
+	}
+	return writeJson(w, http.StatusOK, flows)
+}
+
+func adminGoroutines(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	return rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func adminSetFlowLevel(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logSet := slogd.FromContext(ctx)
+	oopsErr := oops.In("httpd").With("flow", r.PathValue("name"))
+
+	flow, ok := logSet.Flow(r.PathValue("name"))
+	if !ok {
+		return oopsErr.With(StatusKey, http.StatusNotFound).Public("flow not found").New("flow not found")
+	}
+
+	var body struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+		return oopsErr.With(StatusKey, http.StatusBadRequest).Public("invalid request body").Wrap(err)
+	}
+
+	level, ok := slogd.LookupLevel(body.Level)
+	if !ok {
+		return oopsErr.With(StatusKey, http.StatusBadRequest).With("level", body.Level).Public("unknown level").New("unknown level")
+	}
+
+	flow.SetLevel(level)
+	slogd.FromContext(ctx).DefaultLogger().LogAttrs(ctx, slogd.LevelNotice, "log level changed",
+		slog.String("flow", flow.Name()),
+		slog.String("level", slogd.GetLevelName(level)),
+		slog.String("remoteAddress", r.RemoteAddr))
+	return writeJson(w, http.StatusOK, newAdminFlowInfo(flow, logSet.DefaultFlowName()))
+}
+
+func (a *admin) serveVersion(w http.ResponseWriter, r *http.Request) error {
+	return writeJson(w, http.StatusOK, struct {
+		Version   any `json:",omitempty"`
+		GoVersion string
+		StartTime time.Time
+	}{
+		Version:   a.version,
+		GoVersion: runtime.Version(),
+		StartTime: startTime,
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,63 @@
+package httpd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+func TestAdmin(t *testing.T) {
+	newTestFlow("admin")
+	h := Admin(WithAdminVersion(struct{ Full string }{Full: "1.2.3"}))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       io.Reader
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "version", method: http.MethodGet, path: "/version", wantStatus: http.StatusOK, wantBody: `{"Version":{"Full":"1.2.3"},"GoVersion":"go`},
+		{name: "goroutines", method: http.MethodGet, path: "/goroutines", wantStatus: http.StatusOK, wantBody: "goroutine "},
+		{name: "pprof index", method: http.MethodGet, path: "/debug/pprof/", wantStatus: http.StatusOK, wantBody: "heap"},
+		{name: "flows", method: http.MethodGet, path: "/flows", wantStatus: http.StatusOK, wantBody: `{"name":"admin","default":false,"handlers":[{"name":"json","level":"TRACE"}]}`},
+		{name: "flow", method: http.MethodGet, path: "/flows/admin", wantStatus: http.StatusOK, wantBody: `"name":"admin"`},
+		{name: "unknown flow", method: http.MethodGet, path: "/flows/unknown", wantStatus: http.StatusNotFound},
+		{name: "set level", method: http.MethodPut, path: "/flows/admin/level", body: strings.NewReader(`{"level":"warn"}`), wantStatus: http.StatusOK, wantBody: `"level":"WARN"`},
+		{name: "unknown level", method: http.MethodPut, path: "/flows/admin/level", body: strings.NewReader(`{"level":"loud"}`), wantStatus: http.StatusBadRequest},
+		{name: "invalid body", method: http.MethodPut, path: "/flows/admin/level", body: strings.NewReader(`level=warn`), wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, tt.body))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Admin() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Admin() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	flow, _ := slogd.All().Flow("admin")
+	for _, handler := range flow.Handlers() {
+		if level, _ := handler.Level(); level != slogd.LevelWarn {
+			t.Errorf("Admin() handler %s level = %v, want %v", handler.Name(), level, slogd.LevelWarn)
+		}
+	}
+
+	var flows []adminFlowInfo
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flows", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &flows); err != nil || len(flows) == 0 {
+		t.Errorf("Admin() flows = %q, error = %v", w.Body.String(), err)
+	}
+}
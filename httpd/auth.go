@@ -0,0 +1,161 @@
+package httpd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator if the request does not carry credentials it handles.
+	ErrNoCredentials = errors.New("no credentials")
+)
+
+// Authenticator resolves the principal of a request from its credentials.
+// It returns ErrNoCredentials if the request does not carry credentials it handles, so the next authenticator can be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Challenger is implemented by authenticators that add a WWW-Authenticate challenge to 401 responses.
+type Challenger interface {
+	Challenge() string
+}
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	Subject string
+	Method  string // authentication method, e.g. bearer, basic, hmac or jwt
+	Roles   []string
+	Claims  map[string]any
+}
+
+// HasRole returns true if the principal has the role.
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// PrincipalFromContext returns the principal stored in the context by the Authenticate middleware.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey).(Principal)
+	return p, ok
+}
+
+// Authenticate resolves the principal of every request using the first authenticator accepting its credentials,
+// and stores it in the request context. Requests without valid credentials are rejected with a 401 problem response.
+// Failures are logged at warn level to the default flow of the slogd LogSet in the request context, with the credentials redacted.
+func Authenticate(authenticators ...Authenticator) Middleware {
+	var challenges []string
+	for _, a := range authenticators {
+		if c, ok := a.(Challenger); ok {
+			challenges = append(challenges, c.Challenge())
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticate(r, authenticators)
+			if err != nil {
+				ctx := r.Context()
+				slogd.FromContext(ctx).DefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "authentication failed",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("remoteAddress", r.RemoteAddr),
+					slog.String("authorization", redact(r.Header.Get("Authorization"))),
+					slog.Any("error", err))
+
+				for _, c := range challenges {
+					w.Header().Add("WWW-Authenticate", c)
+				}
+				WriteProblem(w, r, ProblemFromError(err))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalCtxKey, p)))
+		})
+	}
+}
+
+// RequireRoles only allows requests from a principal with at least one of the roles, and rejects others with a 403 problem response.
+// It must be used after Authenticate.
+func RequireRoles(roles ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				WriteError(w, r, unauthenticated("no principal in request context"))
+				return
+			}
+
+			for _, role := range roles {
+				if p.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx := r.Context()
+			slogd.FromContext(ctx).DefaultLogger().LogAttrs(ctx, slogd.LevelWarn, "authorization failed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("subject", p.Subject),
+				slog.Any("requiredRoles", roles))
+			WriteProblem(w, r, NewProblem(http.StatusForbidden, "insufficient permissions"))
+		})
+	}
+}
+
+// authenticate returns the principal of the first authenticator accepting the credentials of the request.
+// If none accepts them, the error of the first authenticator that handled the credentials is returned.
+func authenticate(r *http.Request, authenticators []Authenticator) (Principal, error) {
+	var firstErr error
+	for _, a := range authenticators {
+		p, err := a.Authenticate(r)
+		switch {
+		case err == nil:
+			return p, nil
+		case errors.Is(err, ErrNoCredentials):
+			continue
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return Principal{}, firstErr
+	}
+	return Principal{}, unauthenticated("no credentials")
+}
+
+// redact replaces credentials by a short fingerprint, keeping the authorization scheme.
+func redact(credentials string) string {
+	if credentials == "" {
+		return ""
+	}
+
+	scheme, value, found := strings.Cut(credentials, " ")
+	if !found {
+		scheme, value = "", credentials
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	return strings.TrimSpace(scheme + " sha256:" + hex.EncodeToString(sum[:4]))
+}
+
+// unauthenticated returns an error resulting in a 401 problem response, without exposing the reason.
+func unauthenticated(msg string) error {
+	return oops.In("httpd").
+		Code("unauthenticated").
+		With(StatusKey, http.StatusUnauthorized).
+		Public("authentication required").
+		New(msg)
+}
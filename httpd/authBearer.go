@@ -0,0 +1,62 @@
+package httpd
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// NewBearerAuthenticator authenticates requests with one of the static bearer tokens in the Authorization header.
+// Tokens are compared in constant time.
+func NewBearerAuthenticator(tokens map[string]Principal) Authenticator {
+	a := &bearerAuthenticator{}
+	for token, p := range tokens {
+		p.Method = "bearer"
+		a.tokens = append(a.tokens, bearerToken{hash: sha256.Sum256([]byte(token)), principal: p})
+	}
+	return a
+}
+
+type bearerAuthenticator struct {
+	tokens []bearerToken
+}
+
+type bearerToken struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+func (a *bearerAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := bearerTokenFromHeader(r)
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+
+	// Compare against all tokens, so the duration does not depend on which token matches
+	hash := sha256.Sum256([]byte(token))
+	match := -1
+	for i, t := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash[:]) == 1 {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		return Principal{}, unauthenticated("invalid bearer token")
+	}
+	return a.tokens[match].principal, nil
+}
+
+func (a *bearerAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// bearerTokenFromHeader returns the token of a bearer Authorization header.
+func bearerTokenFromHeader(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,94 @@
+package httpd
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samber/oops"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// dummyHash is compared for unknown users, so the duration does not reveal whether a user exists
+	dummyHash = sync.OnceValue(func() []byte {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		return hash
+	})
+)
+
+// NewHtpasswdAuthenticator authenticates requests using HTTP Basic authentication against the users in an htpasswd file.
+// Only bcrypt hashes are supported, as created by htpasswd -B. Roles assigns optional roles to users.
+func NewHtpasswdAuthenticator(path string, realm string, roles map[string][]string) (Authenticator, error) {
+	oopsErr := oops.In("httpd").With("path", path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+	defer f.Close()
+
+	a := &htpasswdAuthenticator{
+		realm: realm,
+		users: make(map[string][]byte),
+		roles: roles,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		user, hash, found := strings.Cut(entry, ":")
+		if !found || user == "" {
+			return nil, oopsErr.With("line", line).New("invalid htpasswd entry")
+		}
+
+		if _, err = bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, oopsErr.With("line", line).With("user", user).Wrapf(err, "unsupported password hash, only bcrypt is supported")
+		}
+		a.users[user] = []byte(hash)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+	return a, nil
+}
+
+type htpasswdAuthenticator struct {
+	realm string
+	users map[string][]byte
+	roles map[string][]string
+}
+
+func (a *htpasswdAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+
+	hash, exists := a.users[user]
+	if !exists {
+		hash = dummyHash()
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !exists {
+		return Principal{}, unauthenticated("invalid username or password")
+	}
+
+	return Principal{
+		Subject: user,
+		Method:  "basic",
+		Roles:   a.roles[user],
+	}, nil
+}
+
+func (a *htpasswdAuthenticator) Challenge() string {
+	return "Basic realm=" + strconv.Quote(a.realm) + `, charset="UTF-8"`
+}
@@ -0,0 +1,333 @@
+package httpd
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/samber/oops"
+)
+
+const (
+	DefaultJwtLeeway     = time.Minute
+	DefaultJwtRolesClaim = "roles"
+)
+
+// JwtOption configures the JWT authenticator.
+type JwtOption func(a *jwtAuthenticator)
+
+// NewJwtAuthenticator authenticates requests with a JSON Web Token in a bearer Authorization header, verified against the keys in a local
+// JWKS file. RSA (RS256, RS384, RS512, PS256, PS384, PS512), ECDSA (ES256, ES384, ES512) and Ed25519 (EdDSA) signatures are supported.
+// The exp and nbf claims are verified if present, and the iss and aud claims if configured using WithJwtIssuer and WithJwtAudience.
+func NewJwtAuthenticator(jwksFile string, opts ...JwtOption) (Authenticator, error) {
+	oopsErr := oops.In("httpd").With("jwksFile", jwksFile)
+
+	b, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+
+	a := &jwtAuthenticator{
+		leeway:     DefaultJwtLeeway,
+		rolesClaim: DefaultJwtRolesClaim,
+	}
+	if a.keys, err = parseJwks(b); err != nil {
+		return nil, oopsErr.Wrap(err)
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// WithJwtAudience requires the aud claim to contain the audience.
+func WithJwtAudience(audience string) JwtOption {
+	return func(a *jwtAuthenticator) {
+		a.audience = audience
+	}
+}
+
+// WithJwtIssuer requires the iss claim to equal the issuer.
+func WithJwtIssuer(issuer string) JwtOption {
+	return func(a *jwtAuthenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithJwtLeeway sets the allowed clock skew when verifying the exp and nbf claims.
+func WithJwtLeeway(d time.Duration) JwtOption {
+	return func(a *jwtAuthenticator) {
+		a.leeway = d
+	}
+}
+
+// WithJwtRolesClaim sets the claim holding the roles of the principal, either as an array or a space separated string.
+func WithJwtRolesClaim(name string) JwtOption {
+	return func(a *jwtAuthenticator) {
+		a.rolesClaim = name
+	}
+}
+
+type jwtAuthenticator struct {
+	keys       []jwk
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	rolesClaim string
+}
+
+type jwk struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := bearerTokenFromHeader(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	claims, err := a.verify(token, time.Now())
+	if err != nil {
+		return Principal{}, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	return Principal{
+		Subject: sub,
+		Method:  "jwt",
+		Roles:   claimStrings(claims[a.rolesClaim]),
+		Claims:  claims,
+	}, nil
+}
+
+func (a *jwtAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// verify checks the signature and registered claims of the token and returns its claims.
+func (a *jwtAuthenticator) verify(token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+
+	var header jwtHeader
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return nil, unauthenticated("invalid jwt header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, unauthenticated("invalid jwt signature encoding")
+	}
+
+	verified := false
+	for _, k := range a.keys {
+		if (header.Kid != "" && k.kid != header.Kid) || (k.alg != "" && k.alg != header.Alg) {
+			continue
+		}
+
+		if verifyJwtSignature(header.Alg, k.key, parts[0]+"."+parts[1], signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, unauthenticated("invalid jwt signature")
+	}
+
+	var claims map[string]any
+	if err = decodeJwtPart(parts[1], &claims); err != nil {
+		return nil, unauthenticated("invalid jwt claims")
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.leeway)) {
+		return nil, unauthenticated("jwt expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-a.leeway)) {
+		return nil, unauthenticated("jwt not yet valid")
+	}
+
+	if iss, _ := claims["iss"].(string); a.issuer != "" && iss != a.issuer {
+		return nil, unauthenticated("invalid jwt issuer")
+	}
+
+	if a.audience != "" && !slices.Contains(claimStrings(claims["aud"]), a.audience) {
+		return nil, unauthenticated("invalid jwt audience")
+	}
+	return claims, nil
+}
+
+// claimStrings returns a claim holding an array of strings or a space separated string as a slice.
+func claimStrings(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func decodeJwtPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifyJwtSignature verifies the signature of the signing input for the algorithm, which must match the type of the key.
+func verifyJwtSignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) bool {
+	if len(alg) < 5 {
+		return false
+	}
+
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		if alg != "EdDSA" {
+			return false
+		}
+	}
+
+	digest := func() []byte {
+		h := hash.New()
+		h.Write([]byte(signingInput))
+		return h.Sum(nil)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "RS384", "RS512":
+			return rsa.VerifyPKCS1v15(k, hash, digest(), signature) == nil
+		case "PS256", "PS384", "PS512":
+			return rsa.VerifyPSS(k, hash, digest(), signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		curves := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+		curve, ok := curves[alg]
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !ok || k.Curve != curve || len(signature) != 2*size {
+			return false
+		}
+		return ecdsa.Verify(k, digest(), new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:]))
+	case ed25519.PublicKey:
+		return alg == "EdDSA" && ed25519.Verify(k, []byte(signingInput), signature)
+	}
+	return false
+}
+
+// parseJwks parses the public keys of a JSON Web Key Set. Keys that are not used for signatures are skipped.
+func parseJwks(b []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, oops.In("httpd").Wrapf(err, "invalid jwks")
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		oopsErr := oops.In("httpd").With("kid", k.Kid).With("kty", k.Kty)
+		decode := func(s string) []byte {
+			b, _ := base64.RawURLEncoding.DecodeString(s)
+			return b
+		}
+
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, e := decode(k.N), decode(k.E)
+			if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, oopsErr.New("invalid rsa key")
+			}
+			key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curves := map[string]struct {
+				curve elliptic.Curve
+				ecdh  ecdh.Curve
+			}{
+				"P-256": {elliptic.P256(), ecdh.P256()},
+				"P-384": {elliptic.P384(), ecdh.P384()},
+				"P-521": {elliptic.P521(), ecdh.P521()},
+			}
+			c, ok := curves[k.Crv]
+			if !ok {
+				return nil, oopsErr.With("crv", k.Crv).New("unsupported curve")
+			}
+
+			// Validate the point is on the curve
+			size := (c.curve.Params().BitSize + 7) / 8
+			x, y := decode(k.X), decode(k.Y)
+			if len(x) != size || len(y) != size {
+				return nil, oopsErr.New("invalid ec key")
+			}
+			if _, err := c.ecdh.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+				return nil, oopsErr.Wrapf(err, "invalid ec key")
+			}
+			key = &ecdsa.PublicKey{Curve: c.curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		case "OKP":
+			x := decode(k.X)
+			if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+				return nil, oopsErr.With("crv", k.Crv).New("unsupported okp key")
+			}
+			key = ed25519.PublicKey(x)
+		default:
+			return nil, oopsErr.New("unsupported key type")
+		}
+
+		keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return keys, nil
+}
@@ -0,0 +1,231 @@
+package httpd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func principalHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		_, _ = w.Write([]byte(p.Method + ":" + p.Subject + ":" + strings.Join(p.Roles, ",")))
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	htpasswdFile := filepath.Join(dir, "htpasswd")
+	_ = os.WriteFile(htpasswdFile, []byte("# users\nalice:"+string(hash)+"\n"), 0600)
+
+	htpasswd, err := NewHtpasswdAuthenticator(htpasswdFile, "test", map[string][]string{"alice": {"admin"}})
+	if err != nil {
+		t.Fatalf("NewHtpasswdAuthenticator() error = %v", err)
+	}
+
+	h := Authenticate(
+		NewBearerAuthenticator(map[string]Principal{"token-1": {Subject: "service", Roles: []string{"reader"}}}),
+		htpasswd,
+		NewHmacAuthenticator(map[string]HmacKey{"client": {Key: []byte("shared-key")}}, time.Minute),
+	)(principalHandler())
+
+	tests := []struct {
+		name       string
+		prepare    func(r *http.Request)
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "bearer", prepare: func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-1") }, wantStatus: http.StatusOK, wantBody: "bearer:service:reader"},
+		{name: "invalid bearer", prepare: func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-2") }, wantStatus: http.StatusUnauthorized},
+		{name: "basic", prepare: func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, wantStatus: http.StatusOK, wantBody: "basic:alice:admin"},
+		{name: "invalid password", prepare: func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", prepare: func(r *http.Request) { r.SetBasicAuth("bob", "secret") }, wantStatus: http.StatusUnauthorized},
+		{name: "hmac", prepare: func(r *http.Request) { _ = SignRequest(r, "client", []byte("shared-key")) }, wantStatus: http.StatusOK, wantBody: "hmac:client:"},
+		{name: "hmac wrong key", prepare: func(r *http.Request) { _ = SignRequest(r, "client", []byte("other-key")) }, wantStatus: http.StatusUnauthorized},
+		{
+			name: "hmac modified body",
+			prepare: func(r *http.Request) {
+				_ = SignRequest(r, "client", []byte("shared-key"))
+				r.Body = http.NoBody
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{name: "no credentials", prepare: func(r *http.Request) {}, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/resource?x=1", strings.NewReader(`{"a":1}`))
+			tt.prepare(r)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Authenticate() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.String() != tt.wantBody {
+				t.Errorf("Authenticate() = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && len(w.Header().Values("WWW-Authenticate")) != 3 {
+				t.Errorf("Authenticate() WWW-Authenticate = %v, want 3 challenges", w.Header().Values("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	h := Authenticate(NewBearerAuthenticator(map[string]Principal{
+		"admin":  {Subject: "admin", Roles: []string{"admin"}},
+		"reader": {Subject: "reader", Roles: []string{"reader"}},
+	}))(RequireRoles("admin", "operator")(principalHandler()))
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "allowed", token: "admin", wantStatus: http.StatusOK},
+		{name: "forbidden", token: "reader", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("RequireRoles() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// signJwt creates a token signed with the key for the algorithm.
+func signJwt(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJwtAuthenticator(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": encode(edKey.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "", "e": ""},
+	}})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	_ = os.WriteFile(jwksFile, jwks, 0600)
+
+	a, err := NewJwtAuthenticator(jwksFile, WithJwtIssuer("https://issuer"), WithJwtAudience("api"))
+	if err != nil {
+		t.Fatalf("NewJwtAuthenticator() error = %v", err)
+	}
+
+	valid := func() map[string]any {
+		return map[string]any{"sub": "alice", "iss": "https://issuer", "aud": []string{"api"}, "exp": time.Now().Add(time.Hour).Unix(), "roles": "admin reader"}
+	}
+	with := func(k string, v any) map[string]any {
+		claims := valid()
+		claims[k] = v
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "rs256", token: signJwt(t, "RS256", "rsa", rsaKey, valid())},
+		{name: "es256", token: signJwt(t, "ES256", "ec", ecKey, valid())},
+		{name: "eddsa", token: signJwt(t, "EdDSA", "ed", edKey, valid())},
+		{name: "without kid", token: signJwt(t, "ES256", "", ecKey, valid())},
+		{name: "algorithm mismatch", token: signJwt(t, "ES256", "rsa", ecKey, valid()), wantErr: true},
+		{name: "unknown key", token: signJwt(t, "ES256", "ec", otherKey, valid()), wantErr: true},
+		{name: "none algorithm", token: strings.Join(strings.Split(signJwt(t, "none", "ec", ecKey, valid()), ".")[:2], ".") + ".", wantErr: true},
+		{name: "expired", token: signJwt(t, "ES256", "ec", ecKey, with("exp", time.Now().Add(-time.Hour).Unix())), wantErr: true},
+		{name: "not yet valid", token: signJwt(t, "ES256", "ec", ecKey, with("nbf", time.Now().Add(time.Hour).Unix())), wantErr: true},
+		{name: "wrong issuer", token: signJwt(t, "ES256", "ec", ecKey, with("iss", "https://other")), wantErr: true},
+		{name: "wrong audience", token: signJwt(t, "ES256", "ec", ecKey, with("aud", "other")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+
+			p, err := a.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.Subject != "alice" || !p.HasRole("admin") || !p.HasRole("reader")) {
+				t.Errorf("Authenticate() = %+v", p)
+			}
+		})
+	}
+}
+
+func Test_redact(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials string
+		want        string
+	}{
+		{name: "empty", credentials: "", want: ""},
+		{name: "bearer", credentials: "Bearer token-1", want: "Bearer sha256:"},
+		{name: "without scheme", credentials: "token-1", want: "sha256:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redact(tt.credentials)
+			if !strings.HasPrefix(got, tt.want) || strings.Contains(got, "token-1") {
+				t.Errorf("redact() = %q, want prefix %q without credentials", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package httpd
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oudwins/zog"
+	"github.com/samber/oops"
+)
+
+const (
+	DefaultBindMaxBytes = 1 << 20
+)
+
+// BindOption configures how a request is bound.
+type BindOption func(b *binder)
+
+// ValidationIssue describes an invalid field of a bound request.
+type ValidationIssue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+// ValidationError is returned when a bound request does not pass its zog schema.
+// ProblemFromError converts it to a 422 problem listing the issues in its issues member.
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		fields[i] = issue.Field + ": " + issue.Message
+	}
+	return "validation failed: " + strings.Join(fields, ", ")
+}
+
+type binder struct {
+	maxBytes      int64
+	schema        *zog.StructSchema
+	unknownFields bool
+	fieldName     func(field reflect.StructField) string
+}
+
+// WithBindMaxBytes sets the maximum size of the request body. Larger bodies result in a 413 problem. By default, DefaultBindMaxBytes is used.
+func WithBindMaxBytes(n int64) BindOption {
+	return func(b *binder) {
+		b.maxBytes = n
+	}
+}
+
+// WithBindSchema validates the bound struct against the zog schema. The shape keys of the schema are the Go field names, not their
+// json or form tags, e.g. z.Shape{"userName": z.String().Required()} validates the UserName field.
+func WithBindSchema(schema *zog.StructSchema) BindOption {
+	return func(b *binder) {
+		b.schema = schema
+	}
+}
+
+// WithBindUnknownFields accepts fields that do not exist in the destination struct, which are rejected with a 400 problem by default.
+func WithBindUnknownFields() BindOption {
+	return func(b *binder) {
+		b.unknownFields = true
+	}
+}
+
+// BindJson decodes the JSON request body into the struct pointed to by dst and validates it.
+//
+// The errors returned carry the HTTP status to answer with when passed to WriteError: 415 if the content type is not JSON,
+// 413 if the body is too large, 400 if the body is not a single valid JSON value or has unknown fields, and 422 with a ValidationError
+// if the struct does not pass the schema.
+func BindJson(w http.ResponseWriter, r *http.Request, dst any, opts ...BindOption) error {
+	b := newBinder(jsonFieldName, opts)
+	oopsErr := oops.FromContext(r.Context()).In("httpd")
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return oopsErr.With(StatusKey, http.StatusUnsupportedMediaType).Public("content type must be application/json").Errorf("unsupported content type %q", mediaType)
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, b.maxBytes))
+	if !b.unknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(dst); err != nil {
+		return jsonBindError(oopsErr, err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return oopsErr.Wrap(err)
+		}
+		return oopsErr.With(StatusKey, http.StatusBadRequest).Public("request body must contain a single json value").New("trailing data after json value")
+	}
+	return b.validate(r, dst)
+}
+
+// BindForm decodes the url-encoded or multipart form in the request body into the struct pointed to by dst and validates it.
+// Query parameters are not included, use BindQuery for those.
+//
+// A field is bound to the value named by its form tag, its json tag, or its name with a lowercase first letter. Strings, booleans,
+// numbers, durations, types implementing encoding.TextUnmarshaler, pointers to them and slices of them are supported.
+// The errors returned carry the HTTP status to answer with when passed to WriteError, like BindJson.
+func BindForm(w http.ResponseWriter, r *http.Request, dst any, opts ...BindOption) error {
+	b := newBinder(bindFieldName, opts)
+	oopsErr := oops.FromContext(r.Context()).In("httpd")
+
+	r.Body = http.MaxBytesReader(w, r.Body, b.maxBytes)
+
+	var err error
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "application/x-www-form-urlencoded":
+		err = r.ParseForm()
+	case "multipart/form-data":
+		err = r.ParseMultipartForm(b.maxBytes)
+	default:
+		return oopsErr.With(StatusKey, http.StatusUnsupportedMediaType).Public("content type must be a form").Errorf("unsupported content type %q", mediaType)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return oopsErr.Wrap(err)
+		}
+		return oopsErr.With(StatusKey, http.StatusBadRequest).Public("invalid form").Wrap(err)
+	}
+
+	if err = b.bindValues(oopsErr, r.PostForm, dst); err != nil {
+		return err
+	}
+	return b.validate(r, dst)
+}
+
+// BindQuery decodes the query parameters of the request into the struct pointed to by dst and validates it.
+// Fields are bound like BindForm. The errors returned carry the HTTP status to answer with when passed to WriteError, like BindJson.
+func BindQuery(r *http.Request, dst any, opts ...BindOption) error {
+	b := newBinder(bindFieldName, opts)
+	oopsErr := oops.FromContext(r.Context()).In("httpd")
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return oopsErr.With(StatusKey, http.StatusBadRequest).Public("invalid query").Wrap(err)
+	}
+
+	if err = b.bindValues(oopsErr, query, dst); err != nil {
+		return err
+	}
+	return b.validate(r, dst)
+}
+
+func newBinder(fieldName func(field reflect.StructField) string, opts []BindOption) *binder {
+	b := &binder{
+		maxBytes:  DefaultBindMaxBytes,
+		fieldName: fieldName,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// bindValues sets the fields of the struct pointed to by dst from the values.
+func (b *binder) bindValues(oopsErr oops.OopsErrorBuilder, values url.Values, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return oopsErr.Errorf("destination must be a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+
+	fields := make(map[string]reflect.Value)
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if name := bindFieldName(field); field.IsExported() && name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+
+	for name, vals := range values {
+		field, ok := fields[name]
+		if !ok {
+			if b.unknownFields {
+				continue
+			}
+			return oopsErr.With(StatusKey, http.StatusBadRequest).Public(fmt.Sprintf("unknown field %q", name)).Errorf("unknown field %q", name)
+		}
+
+		if err := setField(field, vals); err != nil {
+			return oopsErr.With(StatusKey, http.StatusBadRequest).Public(fmt.Sprintf("invalid value for field %q", name)).Wrap(err)
+		}
+	}
+	return nil
+}
+
+// validate runs the schema against the bound struct, returning a ValidationError if it does not pass.
+// The fields of the issues are named like the client named them, rather than after the shape keys of the schema.
+func (b *binder) validate(r *http.Request, dst any) (err error) {
+	if b.schema == nil {
+		return nil
+	}
+
+	// zog panics if a shape key of the schema has no matching field in the struct
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = oops.FromContext(r.Context()).In("httpd").With("schema", rec).Errorf("bind schema does not match %T", dst)
+		}
+	}()
+
+	issues := b.schema.Validate(dst)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	validationErr := &ValidationError{Issues: make([]ValidationIssue, len(issues))}
+	for i, issue := range issues {
+		validationErr.Issues[i] = ValidationIssue{
+			Field:   b.issueField(reflect.TypeOf(dst), issue.Path),
+			Message: issue.Message,
+			Code:    issue.Code,
+		}
+	}
+
+	// The schema validates its fields in random order
+	slices.SortStableFunc(validationErr.Issues, func(a, b ValidationIssue) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return oops.FromContext(r.Context()).In("httpd").With(StatusKey, http.StatusUnprocessableEntity).Public("request validation failed").Wrap(validationErr)
+}
+
+// issueField converts the path of a zog issue, which consists of shape keys and slice indexes, to the names of the fields.
+func (b *binder) issueField(t reflect.Type, path []string) string {
+	var sb strings.Builder
+	for _, segment := range path {
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		if strings.HasPrefix(segment, "[") {
+			sb.WriteString(segment)
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				t = t.Elem()
+			} else {
+				t = nil
+			}
+			continue
+		}
+
+		name := segment
+		if field, ok := schemaField(t, segment); ok {
+			if name = b.fieldName(field); name == "" {
+				name = segment
+			}
+			t = field.Type
+		} else {
+			t = nil
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(name)
+	}
+	return sb.String()
+}
+
+func bindFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"form", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name == "-" {
+			return ""
+		} else if name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name[:1]) + field.Name[1:]
+}
+
+// jsonBindError converts a json decoding error to an error with a public message the client can act on.
+func jsonBindError(oopsErr oops.OopsErrorBuilder, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	badRequest := oopsErr.With(StatusKey, http.StatusBadRequest)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return oopsErr.Wrap(err)
+	case errors.Is(err, io.EOF):
+		return badRequest.Public("request body is empty").Wrap(err)
+	case errors.As(err, &syntaxErr):
+		return badRequest.Public(fmt.Sprintf("invalid json at offset %d", syntaxErr.Offset)).Wrap(err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest.Public("invalid json: unexpected end of input").Wrap(err)
+	case errors.As(err, &typeErr):
+		return badRequest.Public(fmt.Sprintf("invalid value for field %q", typeErr.Field)).Wrap(err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The json package has no error type for unknown fields
+		return badRequest.Public(strings.TrimPrefix(err.Error(), "json: ")).Wrap(err)
+	default:
+		return badRequest.Public("invalid json").Wrap(err)
+	}
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// setField sets the field from the values, using all values for slices and the first value otherwise.
+func setField(field reflect.Value, vals []string) error {
+	if field.Kind() == reflect.Slice && !field.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if len(vals) == 0 {
+		return nil
+	}
+	return setValue(field, vals[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
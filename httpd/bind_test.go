@@ -0,0 +1,223 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	z "github.com/Oudwins/zog"
+)
+
+type bindTestUser struct {
+	Name    string        `json:"name"`
+	Age     int           `json:"age"`
+	Tags    []string      `json:"tags"`
+	Timeout time.Duration `json:"timeout"`
+	Admin   *bool         `json:"admin"`
+	Email   string        `json:"email_address"`
+}
+
+var bindTestSchema = z.Struct(z.Shape{
+	"name":  z.String().Required(),
+	"age":   z.Int().GTE(18),
+	"email": z.String().Email(),
+})
+
+// bindHandler binds the request using bind, and answers with the bound user or the problem.
+func bindHandler(bind func(w http.ResponseWriter, r *http.Request, dst any) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user bindTestUser
+		if err := bind(w, r, &user); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(user)
+	})
+}
+
+func TestBindJson(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []BindOption
+		wantStatus  int
+		wantIssues  []string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"name":"john","age":30,"tags":["a"]}`, wantStatus: http.StatusOK},
+		{name: "json suffix", contentType: "application/merge-patch+json; charset=utf-8", body: `{"name":"john","age":30}`, wantStatus: http.StatusOK},
+		{name: "wrong content type", contentType: "text/plain", body: `{"name":"john"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "empty body", contentType: "application/json", wantStatus: http.StatusBadRequest},
+		{name: "syntax error", contentType: "application/json", body: `{"name":}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong type", contentType: "application/json", body: `{"age":"old"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", contentType: "application/json", body: `{"name":"john","age":30,"role":"admin"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field allowed", contentType: "application/json", body: `{"name":"john","age":30,"role":"admin"}`, opts: []BindOption{WithBindUnknownFields()}, wantStatus: http.StatusOK},
+		{name: "trailing data", contentType: "application/json", body: `{"name":"john","age":30}{}`, wantStatus: http.StatusBadRequest},
+		{name: "too large", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 100) + `"}`, opts: []BindOption{WithBindMaxBytes(64)}, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "invalid", contentType: "application/json", body: `{"age":12,"email_address":"john"}`, wantStatus: http.StatusUnprocessableEntity, wantIssues: []string{"age", "email_address", "name"}},
+		{
+			name: "schema mismatch", contentType: "application/json", body: `{"name":"john","age":30}`,
+			opts: []BindOption{WithBindSchema(z.Struct(z.Shape{"email_address": z.String()}))}, wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]BindOption{WithBindSchema(bindTestSchema)}, tt.opts...)
+			h := bindHandler(func(w http.ResponseWriter, r *http.Request, dst any) error {
+				return BindJson(w, r, dst, opts...)
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("BindJson() status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			checkIssues(t, w, tt.wantIssues)
+		})
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		form        url.Values
+		wantStatus  int
+		wantIssues  []string
+	}{
+		{
+			name:        "valid",
+			contentType: "application/x-www-form-urlencoded",
+			form:        url.Values{"name": {"john"}, "age": {"30"}, "tags": {"a", "b"}, "timeout": {"5s"}, "admin": {"true"}},
+			wantStatus:  http.StatusOK,
+		},
+		{name: "wrong content type", contentType: "application/json", form: url.Values{"name": {"john"}}, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "invalid number", contentType: "application/x-www-form-urlencoded", form: url.Values{"age": {"old"}}, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", contentType: "application/x-www-form-urlencoded", form: url.Values{"role": {"admin"}}, wantStatus: http.StatusBadRequest},
+		{name: "invalid", contentType: "application/x-www-form-urlencoded", form: url.Values{"name": {"john"}, "age": {"12"}, "email_address": {"john"}}, wantStatus: http.StatusUnprocessableEntity, wantIssues: []string{"age", "email_address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := bindHandler(func(w http.ResponseWriter, r *http.Request, dst any) error {
+				return BindForm(w, r, dst, WithBindSchema(bindTestSchema))
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/?ignored=1", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("BindForm() status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusOK {
+				var got bindTestUser
+				_ = json.Unmarshal(w.Body.Bytes(), &got)
+				if got.Name != "john" || got.Age != 30 || strings.Join(got.Tags, ",") != "a,b" || got.Timeout != 5*time.Second || got.Admin == nil || !*got.Admin {
+					t.Errorf("BindForm() bound %+v", got)
+				}
+			}
+			checkIssues(t, w, tt.wantIssues)
+		})
+	}
+}
+
+func TestBindQuery(t *testing.T) {
+	type search struct {
+		Query   string        `form:"q"`
+		Limit   uint          `form:"limit"`
+		Timeout time.Duration `form:"timeout"`
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    search
+		wantErr bool
+	}{
+		{name: "valid", query: "q=go&limit=10&timeout=1m", want: search{Query: "go", Limit: 10, Timeout: time.Minute}},
+		{name: "empty", query: ""},
+		{name: "negative", query: "limit=-1", wantErr: true},
+		{name: "invalid duration", query: "timeout=soon", wantErr: true},
+		{name: "unknown", query: "page=2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got search
+			err := BindQuery(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BindQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if p := ProblemFromError(err); p.Status != http.StatusBadRequest {
+					t.Errorf("BindQuery() problem status = %d, want %d", p.Status, http.StatusBadRequest)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BindQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinder_issueField(t *testing.T) {
+	type order struct {
+		Users []*bindTestUser `json:"users"`
+		Note  string          `json:"-"`
+	}
+
+	tests := []struct {
+		name string
+		path []string
+		want string
+	}{
+		{name: "empty", want: ""},
+		{name: "field", path: []string{"users"}, want: "users"},
+		{name: "nested", path: []string{"users", "[1]", "email"}, want: "users[1].email_address"},
+		{name: "not encoded", path: []string{"note"}, want: "note"},
+		{name: "unknown", path: []string{"items", "[0]", "name"}, want: "items[0].name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBinder(jsonFieldName, nil)
+			if got := b.issueField(reflect.TypeFor[*order](), tt.path); got != tt.want {
+				t.Errorf("issueField() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkIssues verifies the fields listed in the issues of a validation problem.
+func checkIssues(t *testing.T, w *httptest.ResponseRecorder, want []string) {
+	t.Helper()
+	if len(want) == 0 {
+		return
+	}
+
+	var problem struct {
+		Issues []ValidationIssue `json:"issues"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid problem response: %v", err)
+	}
+
+	var fields []string
+	for _, issue := range problem.Issues {
+		if issue.Message == "" {
+			t.Errorf("issue for %s has no message", issue.Field)
+		}
+		fields = append(fields, issue.Field)
+	}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Errorf("problem issues for fields %v, want %v", fields, want)
+	}
+}
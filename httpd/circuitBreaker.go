@@ -0,0 +1,94 @@
+package httpd
+
+import (
+	"sync"
+	"time"
+)
+
+func newCircuitBreakers(failures int, cooldown time.Duration) *circuitBreakers {
+	if failures <= 0 {
+		failures = DefaultClientBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultClientBreakerCooldown
+	}
+
+	return &circuitBreakers{
+		failures: failures,
+		cooldown: cooldown,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// circuitBreakers holds a circuit breaker per host.
+type circuitBreakers struct {
+	failures int
+	cooldown time.Duration
+	breakers map[string]*circuitBreaker
+	mux      sync.Mutex
+}
+
+// circuitBreaker is closed while requests succeed, and opens after consecutive failures. Once the cooldown has passed,
+// it is half-open: a single probe is let through, which closes the breaker when it succeeds or opens it again when it fails.
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports if a request to the host may be sent.
+func (b *circuitBreakers) allow(host string, now time.Time) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	breaker, ok := b.breakers[host]
+	if !ok || breaker.openUntil.IsZero() {
+		return true
+	}
+
+	if now.Before(breaker.openUntil) || breaker.probing {
+		return false
+	}
+
+	breaker.probing = true
+	return true
+}
+
+// abort lets another request probe the host, when the probe was cancelled before it had an outcome.
+func (b *circuitBreakers) abort(host string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if breaker, ok := b.breakers[host]; ok {
+		breaker.probing = false
+	}
+}
+
+// record updates the breaker of the host with the outcome of a request, and reports if the breaker was opened by this failure.
+func (b *circuitBreakers) record(host string, failed bool, now time.Time) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	breaker, ok := b.breakers[host]
+	if !failed {
+		// Hosts without failures do not need a breaker
+		if ok {
+			delete(b.breakers, host)
+		}
+		return false
+	}
+
+	if !ok {
+		breaker = &circuitBreaker{}
+		b.breakers[host] = breaker
+	}
+
+	breaker.failures++
+	if !breaker.probing && breaker.failures < b.failures {
+		return false
+	}
+
+	breaker.openUntil = now.Add(b.cooldown)
+	breaker.probing = false
+	return true
+}
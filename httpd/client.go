@@ -0,0 +1,361 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	DefaultClientBreakerCooldown = 30 * time.Second
+	DefaultClientBreakerFailures = 5
+	DefaultClientRetryBackoff    = 100 * time.Millisecond
+	DefaultClientRetryMaxBackoff = 5 * time.Second
+	DefaultClientTimeout         = 30 * time.Second
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+
+	// redactedHeaders are the headers whose values are never logged in clear text, even when they are logged using WithClientLogHeaders.
+	redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+)
+
+// ClientOption configures the client created by NewClient.
+type ClientOption func(c *client)
+
+// NewClient creates an HTTP client for calling other services, with the same observability as the server side.
+//
+// Every request is logged with its method, URL without query string, status, attempts and latency, at debug level if it succeeded
+// and at warn or error level if it failed. Headers are only logged when enabled using WithClientLogHeaders. The request ID of the context is sent in the X-Request-ID header, so requests can be correlated
+// across services. Idempotent requests are retried with exponential backoff and jitter when the server cannot be reached, or answers
+// with 429, 502, 503 or 504. Use http.NewRequestWithContext to pass the request ID and to cancel requests with their retries.
+func NewClient(opts ...ClientOption) *http.Client {
+	c := &client{
+		timeout:         DefaultClientTimeout,
+		retryBackoff:    DefaultClientRetryBackoff,
+		retryMaxBackoff: DefaultClientRetryMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		dialer := &net.Dialer{Timeout: c.dialTimeout, KeepAlive: 30 * time.Second}
+		t.DialContext = dialer.DialContext
+		if c.socketPath != "" {
+			t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", c.socketPath)
+			}
+		}
+		t.ResponseHeaderTimeout = c.responseHeaderTimeout
+		c.transport = t
+	}
+
+	return &http.Client{
+		Timeout:   c.timeout,
+		Transport: c,
+	}
+}
+
+// client is the round tripper of the clients created by NewClient.
+type client struct {
+	transport             http.RoundTripper
+	timeout               time.Duration
+	dialTimeout           time.Duration
+	responseHeaderTimeout time.Duration
+	socketPath            string
+	retries               int
+	retryBackoff          time.Duration
+	retryMaxBackoff       time.Duration
+	breaker               *circuitBreakers
+	signKeyID             string
+	signKey               []byte
+	logHeaders            []string
+	flow                  string
+}
+
+// WithClientCircuitBreaker stops sending requests to a host for the cooldown after the number of consecutive failed requests,
+// failing them with ErrCircuitOpen instead. After the cooldown, a single request is let through to probe the host.
+// Requests fail if the host cannot be reached or answers with a 5xx status. Zero values use DefaultClientBreakerFailures
+// and DefaultClientBreakerCooldown.
+func WithClientCircuitBreaker(failures int, cooldown time.Duration) ClientOption {
+	return func(c *client) {
+		c.breaker = newCircuitBreakers(failures, cooldown)
+	}
+}
+
+// WithClientDialTimeout sets the maximum duration for establishing a connection.
+func WithClientDialTimeout(d time.Duration) ClientOption {
+	return func(c *client) {
+		c.dialTimeout = d
+	}
+}
+
+// WithClientFlow logs the requests to the named slogd flow.
+func WithClientFlow(name string) ClientOption {
+	return func(c *client) {
+		c.flow = name
+	}
+}
+
+// WithClientLogHeaders logs the values of the request and response headers with the names, which are not logged by default.
+// The values of Authorization, Cookie, Proxy-Authorization and Set-Cookie are replaced by a fingerprint.
+func WithClientLogHeaders(names ...string) ClientOption {
+	return func(c *client) {
+		c.logHeaders = names
+	}
+}
+
+// WithClientResponseHeaderTimeout sets the maximum duration to wait for the response headers after the request was sent.
+func WithClientResponseHeaderTimeout(d time.Duration) ClientOption {
+	return func(c *client) {
+		c.responseHeaderTimeout = d
+	}
+}
+
+// WithClientRetries sets the number of times a failed idempotent request is retried. The delay before a retry is chosen randomly
+// up to the backoff, which doubles for every retry up to the maximum. A Retry-After header shorter than the maximum is honoured.
+func WithClientRetries(n int, backoff, maxBackoff time.Duration) ClientOption {
+	return func(c *client) {
+		c.retries = n
+		c.retryBackoff = backoff
+		c.retryMaxBackoff = maxBackoff
+	}
+}
+
+// WithClientSigner signs every request with the key using SignRequest, to authenticate with servers using NewHmacAuthenticator.
+func WithClientSigner(keyID string, key []byte) ClientOption {
+	return func(c *client) {
+		c.signKeyID = keyID
+		c.signKey = key
+	}
+}
+
+// WithClientTimeout sets the maximum duration of a request, including its retries and reading the response body. Zero means no timeout.
+func WithClientTimeout(d time.Duration) ClientOption {
+	return func(c *client) {
+		c.timeout = d
+	}
+}
+
+// WithClientTransport sets the transport used to send the requests. The dial and response header timeouts and the unix socket
+// only apply to the default transport.
+func WithClientTransport(t http.RoundTripper) ClientOption {
+	return func(c *client) {
+		c.transport = t
+	}
+}
+
+// WithClientUnixSocket sends all requests to the unix socket, e.g. of a service run using RunSocketHttpServer.
+// The host of the request URL is still used for the Host header and the circuit breaker, e.g. http://unix/path.
+func WithClientUnixSocket(socketPath string) ClientOption {
+	return func(c *client) {
+		c.socketPath = socketPath
+	}
+}
+
+func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+
+	if req.Body != nil && req.GetBody != nil {
+		// Every attempt sends a copy of the body, so the original body is never closed by the transport
+		defer req.Body.Close()
+	}
+
+	retries := 0
+	if retryable(req) {
+		retries = c.retries
+	}
+
+	var resp *http.Response
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		resp, err = c.attempt(req)
+		if attempts > retries || errors.Is(err, ErrCircuitOpen) || !retryStatus(resp, err) || ctx.Err() != nil {
+			break
+		}
+
+		delay := c.backoff(attempts, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.log(req, nil, attempts, time.Since(start), ctx.Err())
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	c.log(req, resp, attempts, time.Since(start), err)
+	return resp, err
+}
+
+// attempt sends a copy of the request once, through the circuit breaker of the host.
+// The body of the copy is closed when the request is not sent, as the transport would close it otherwise.
+func (c *client) attempt(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = body
+	}
+
+	if id, ok := RequestIDFromContext(req.Context()); ok && out.Header.Get(HeaderRequestID) == "" {
+		out.Header.Set(HeaderRequestID, id)
+	}
+
+	if c.signKey != nil {
+		if err := SignRequest(out, c.signKeyID, c.signKey); err != nil {
+			closeBody(out)
+			return nil, err
+		}
+	}
+
+	if c.breaker != nil && !c.breaker.allow(req.URL.Host, time.Now()) {
+		closeBody(out)
+		return nil, oops.FromContext(req.Context()).In("httpd").With("host", req.URL.Host).Wrap(ErrCircuitOpen)
+	}
+
+	resp, err := c.transport.RoundTrip(out)
+	switch {
+	case c.breaker == nil:
+	case req.Context().Err() != nil:
+		// The request was cancelled, which says nothing about the host
+		c.breaker.abort(req.URL.Host)
+	default:
+		if c.breaker.record(req.URL.Host, err != nil || resp.StatusCode >= http.StatusInternalServerError, time.Now()) {
+			log := flowLogger(req.Context(), c.flow)
+			log.LogAttrs(req.Context(), slogd.LevelWarn, "circuit breaker opened",
+				slog.String("host", req.URL.Host),
+				slog.Duration("cooldown", c.breaker.cooldown))
+		}
+	}
+	return resp, err
+}
+
+// backoff returns the delay before the next attempt: a random duration up to the exponential backoff, or the Retry-After of the response.
+func (c *client) backoff(attempts int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if d := time.Duration(seconds) * time.Second; d <= c.retryMaxBackoff {
+				return d
+			}
+		}
+	}
+
+	d := c.retryBackoff << (attempts - 1)
+	if d <= 0 || d > c.retryMaxBackoff {
+		d = c.retryMaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+func (c *client) log(req *http.Request, resp *http.Response, attempts int, latency time.Duration, err error) {
+	ctx := req.Context()
+
+	level := slogd.LevelDebug
+	switch {
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		level = slogd.LevelError
+	case resp.StatusCode >= http.StatusBadRequest:
+		level = slogd.LevelWarn
+	}
+
+	log := flowLogger(ctx, c.flow)
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	// The query string is left out, as it can contain credentials such as the signature of a signed URL
+	u := *req.URL
+	u.RawQuery = ""
+	u.ForceQuery = false
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", u.Redacted()),
+		slog.Int("attempts", attempts),
+		slog.Duration("latency", latency),
+	}
+	if len(c.logHeaders) > 0 {
+		attrs = append(attrs, c.headerAttr("requestHeaders", req.Header))
+	}
+	if resp != nil {
+		attrs = append(attrs,
+			slog.Int("status", resp.StatusCode),
+			slog.String("protocol", resp.Proto))
+		if len(c.logHeaders) > 0 {
+			attrs = append(attrs, c.headerAttr("responseHeaders", resp.Header))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	log.LogAttrs(ctx, level, "http client request", attrs...)
+}
+
+// headerAttr returns the logged headers as a group, with the values of credentials replaced by a fingerprint.
+func (c *client) headerAttr(key string, h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(c.logHeaders))
+	for _, name := range c.logHeaders {
+		name = http.CanonicalHeaderKey(name)
+		value := h.Get(name)
+		if value == "" {
+			continue
+		}
+		if slices.Contains(redactedHeaders, name) {
+			value = redact(value)
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// closeBody closes the body of a request that is not sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// retryStatus reports if the outcome of an attempt is worth retrying.
+func retryStatus(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,194 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyHandler answers with the status for the first failures requests, and with 200 afterwards.
+func flakyHandler(failures int32, status int, calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = io.WriteString(w, r.Header.Get(HeaderRequestID))
+	})
+}
+
+func TestNewClient_Retries(t *testing.T) {
+	newTestFlow("client")
+
+	tests := []struct {
+		name       string
+		failures   int32
+		status     int
+		method     string
+		retries    int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "no failures", method: http.MethodGet, retries: 2, wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "retried", failures: 2, status: http.StatusServiceUnavailable, method: http.MethodGet, retries: 2, wantStatus: http.StatusOK, wantCalls: 3},
+		{name: "retries exhausted", failures: 3, status: http.StatusBadGateway, method: http.MethodGet, retries: 1, wantStatus: http.StatusBadGateway, wantCalls: 2},
+		{name: "not idempotent", failures: 1, status: http.StatusServiceUnavailable, method: http.MethodPost, retries: 2, wantStatus: http.StatusServiceUnavailable, wantCalls: 1},
+		{name: "not retryable status", failures: 1, status: http.StatusInternalServerError, method: http.MethodGet, retries: 2, wantStatus: http.StatusInternalServerError, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(flakyHandler(tt.failures, tt.status, &calls))
+			defer srv.Close()
+
+			client := NewClient(WithClientFlow("client"), WithClientRetries(tt.retries, time.Millisecond, 5*time.Millisecond))
+			req, _ := http.NewRequest(tt.method, srv.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Do() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("Do() sent %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNewClient_CircuitBreaker(t *testing.T) {
+	newTestFlow("client")
+	var calls atomic.Int32
+	srv := httptest.NewServer(flakyHandler(2, http.StatusInternalServerError, &calls))
+	defer srv.Close()
+
+	client := NewClient(WithClientFlow("client"), WithClientCircuitBreaker(2, 50*time.Millisecond))
+	get := func() (int, error) {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	for range 2 {
+		if status, err := get(); err != nil || status != http.StatusInternalServerError {
+			t.Fatalf("Get() = %d, %v, want %d", status, err, http.StatusInternalServerError)
+		}
+	}
+
+	if _, err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Get() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Get() sent %d requests while the circuit breaker was open, want 2", got)
+	}
+
+	// After the cooldown, the probe succeeds and closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	for range 2 {
+		if status, err := get(); err != nil || status != http.StatusOK {
+			t.Errorf("Get() after cooldown = %d, %v, want %d", status, err, http.StatusOK)
+		}
+	}
+}
+
+func TestNewClient_Logging(t *testing.T) {
+	buf := newTestFlow("client-logging")
+	socketPath := filepath.Join(t.TempDir(), "client.sock")
+
+	h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret-session")
+		_, _ = io.WriteString(w, r.Header.Get(HeaderRequestID))
+	}))
+	s := NewServer(h, WithUnixListener(socketPath), WithShutdownTimeout(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	client := NewClient(WithClientFlow("client-logging"), WithClientUnixSocket(socketPath), WithClientRetries(10, 10*time.Millisecond, 10*time.Millisecond),
+		WithClientLogHeaders("authorization", "Set-Cookie", "Accept"))
+
+	reqCtx := context.WithValue(context.Background(), requestIDCtxKey, "client-request-id")
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://unix/path?signature=secret-signature", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-Api-Key", "secret-key")
+	req.Header.Set("Accept", "text/plain")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != "client-request-id" {
+		t.Errorf("Do() sent request ID %q, want client-request-id", body)
+	}
+	if req.Header.Get(HeaderRequestID) != "" {
+		t.Error("Do() modified the request headers")
+	}
+
+	records := decodeRecords(t, buf)
+	record := records[len(records)-1]
+	if record["msg"] != "http client request" || record["level"] != "DEBUG" || record["status"] != float64(http.StatusOK) || record["url"] != "http://unix/path" {
+		t.Errorf("Do() record = %v", record)
+	}
+	if headers, _ := record["requestHeaders"].(map[string]any); headers["Accept"] != "text/plain" || headers["Authorization"] == nil {
+		t.Errorf("Do() logged request headers %v, want Accept and redacted Authorization", record["requestHeaders"])
+	}
+	if line := buf.String(); strings.Contains(line, "secret-") {
+		t.Errorf("Do() logged credentials: %s", line)
+	}
+}
+
+// closeRecorder records if it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewClient_closesBody(t *testing.T) {
+	newTestFlow("client")
+	var calls atomic.Int32
+	srv := httptest.NewServer(flakyHandler(1, http.StatusInternalServerError, &calls))
+	defer srv.Close()
+
+	client := NewClient(WithClientFlow("client"), WithClientCircuitBreaker(1, time.Minute))
+	for i := range 2 {
+		body := &closeRecorder{Reader: strings.NewReader("body")}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, body)
+		resp, err := client.Transport.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		if i == 1 && !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("RoundTrip() error = %v, want %v", err, ErrCircuitOpen)
+		}
+		if !body.closed {
+			t.Errorf("RoundTrip() attempt %d did not close the request body", i+1)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package httpd
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	DefaultCompressMinSize = 1024
+)
+
+var (
+	// DefaultCompressContentTypes lists the media types compressed by default. Entries ending in /* match all subtypes.
+	DefaultCompressContentTypes = []string{
+		"text/*",
+		"application/javascript",
+		"application/json",
+		"application/problem+json",
+		"application/x-ndjson",
+		"application/xml",
+		"image/svg+xml",
+	}
+)
+
+// CompressOption configures the Compress middleware.
+type CompressOption func(c *compressConfig)
+
+type compressConfig struct {
+	level        int
+	minSize      int
+	contentTypes []string
+	encoders     []*compressEncoding
+}
+
+// compressEncoding pools the writers of a content coding, in order of preference.
+type compressEncoding struct {
+	name string
+	pool sync.Pool
+}
+
+// compressor is implemented by the writers of all supported content codings.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress compresses responses using zstd, brotli, gzip or deflate, in that order of preference, negotiated with the Accept-Encoding
+// header of the request.
+// Only responses of at least DefaultCompressMinSize bytes with a content type in DefaultCompressContentTypes are compressed, and responses
+// that are already encoded are left alone. Range requests are never compressed, as the ranges refer to the uncompressed content.
+// Flushing a response compresses it regardless of its size, so streamed responses are compressed as they are written.
+func Compress(opts ...CompressOption) Middleware {
+	c := &compressConfig{
+		level:        gzip.DefaultCompression,
+		minSize:      DefaultCompressMinSize,
+		contentTypes: DefaultCompressContentTypes,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.encoders = []*compressEncoding{
+		{name: "zstd", pool: sync.Pool{New: func() any {
+			// Browsers limit the window to 8 MB, and a single goroutine suits pooled writers
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(c.zstdLevel()), zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+			return w
+		}}},
+		{name: "br", pool: sync.Pool{New: func() any {
+			return brotli.NewWriterLevel(io.Discard, c.brotliLevel())
+		}}},
+		{name: "gzip", pool: sync.Pool{New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, c.level)
+			return w
+		}}},
+		{name: "deflate", pool: sync.Pool{New: func() any {
+			w, _ := flate.NewWriter(io.Discard, c.level)
+			return w
+		}}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == nil || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, config: c, encoding: encoding}
+			completed := false
+			defer func() {
+				if !completed {
+					// The handler panicked, the buffered response is left unwritten so it can be replaced by an error response
+					cw.release()
+					return
+				}
+				cw.close()
+			}()
+
+			next.ServeHTTP(cw, r)
+			completed = true
+		})
+	}
+}
+
+// WithCompressContentTypes sets the media types that are compressed. Entries ending in /* match all subtypes, e.g. text/*.
+func WithCompressContentTypes(contentTypes ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.contentTypes = contentTypes
+	}
+}
+
+// WithCompressLevel sets the compression level, from flate.BestSpeed to flate.BestCompression. Invalid levels use the default compression.
+// The level is mapped to the closest level of zstd and brotli.
+func WithCompressLevel(level int) CompressOption {
+	return func(c *compressConfig) {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			level = flate.DefaultCompression
+		}
+		c.level = level
+	}
+}
+
+// WithCompressMinSize sets the minimum size in bytes of responses to compress, as compressing small responses makes them larger.
+func WithCompressMinSize(n int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = n
+	}
+}
+
+// brotliLevel returns the brotli quality for the compression level.
+func (c *compressConfig) brotliLevel() int {
+	if c.level < flate.BestSpeed {
+		return brotli.DefaultCompression
+	}
+	return c.level
+}
+
+// zstdLevel returns the zstd encoder level for the compression level.
+func (c *compressConfig) zstdLevel() zstd.EncoderLevel {
+	if c.level < flate.BestSpeed {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(c.level)
+}
+
+// allowed reports if the content type is in the list of compressed media types.
+func (c *compressConfig) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range c.contentTypes {
+		if prefix, found := strings.CutSuffix(t, "/*"); found && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate returns the preferred encoding accepted by the client, or nil if the response must not be compressed.
+func (c *compressConfig) negotiate(acceptEncoding string) *compressEncoding {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	for _, e := range c.encoders {
+		if acceptsEncoding(acceptEncoding, e.name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// compressWriter buffers the start of the response until it knows if the response is large enough to be compressed.
+type compressWriter struct {
+	http.ResponseWriter
+	config     *compressConfig
+	encoding   *compressEncoding
+	status     int
+	buf        []byte
+	decided    bool
+	compressor compressor
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+
+	if w.compressor != nil {
+		_ = w.compressor.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	// The connection is no longer an HTTP response, so nothing is written on close
+	w.decided = true
+	return h.Hijack()
+}
+
+// Unwrap returns the original http.ResponseWriter, for use with http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.config.minSize {
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	// Informational responses are followed by the final response
+	if code >= http.StatusContinue && code < http.StatusOK && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = code
+	}
+
+	// Responses without a body cannot be compressed
+	if !w.decided && (code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusSwitchingProtocols) {
+		_ = w.decide(false)
+	}
+}
+
+// close writes the buffered response if it was too small to be compressed and terminates the compressed stream.
+func (w *compressWriter) close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+	w.release()
+}
+
+// release returns the compressor to the pool without terminating the compressed stream.
+func (w *compressWriter) release() {
+	if w.compressor != nil {
+		w.compressor.Reset(io.Discard)
+		w.encoding.pool.Put(w.compressor)
+		w.compressor = nil
+	}
+}
+
+// decide sends the header and the buffered content, compressed if the response qualifies. Flushing compresses responses below the minimum size.
+func (w *compressWriter) decide(flushing bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if w.compressible(flushing) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding.name)
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			// The compressed content is not byte-for-byte identical to the uncompressed content
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.compressor = w.encoding.pool.Get().(compressor)
+		w.compressor.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) compressible(flushing bool) bool {
+	h := w.Header()
+	switch {
+	case w.status < http.StatusOK, w.status == http.StatusNoContent, w.status == http.StatusNotModified, w.status == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "", h.Get("Content-Range") != "":
+		return false
+	case !flushing && len(w.buf) < w.config.minSize:
+		return false
+	default:
+		return w.config.allowed(h.Get("Content-Type"))
+	}
+}
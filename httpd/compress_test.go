@@ -0,0 +1,124 @@
+package httpd
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"key":"value"},`, 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		rangeHeader    string
+		contentType    string
+		body           string
+		flush          bool
+		wantEncoding   string
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", contentType: "application/json", body: large, wantEncoding: "gzip"},
+		{name: "deflate", acceptEncoding: "deflate, gzip;q=0", contentType: "application/json", body: large, wantEncoding: "deflate"},
+		{name: "sniffed content type", acceptEncoding: "gzip", body: large, wantEncoding: "gzip"},
+		{name: "zstd", acceptEncoding: "gzip, br, zstd", contentType: "application/json", body: large, wantEncoding: "zstd"},
+		{name: "brotli", acceptEncoding: "gzip, br", contentType: "application/json", body: large, wantEncoding: "br"},
+		{name: "not accepted", acceptEncoding: "compress", contentType: "application/json", body: large},
+		{name: "below threshold", acceptEncoding: "gzip", contentType: "application/json", body: `{"key":"value"}`},
+		{name: "flushed below threshold", acceptEncoding: "gzip", contentType: "text/event-stream", body: "data: event\n\n", flush: true, wantEncoding: "gzip"},
+		{name: "content type not allowed", acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{name: "range request", acceptEncoding: "gzip", rangeHeader: "bytes=0-10", contentType: "application/json", body: large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Compress()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("ETag", `"abc"`)
+				_, _ = io.WriteString(w, tt.body)
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.rangeHeader != "" {
+				r.Header.Set("Range", tt.rangeHeader)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Compress() Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Compress() Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+			}
+
+			var body io.Reader = w.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				body, _ = gzip.NewReader(w.Body)
+			case "deflate":
+				body = flate.NewReader(w.Body)
+			case "br":
+				body = brotli.NewReader(w.Body)
+			case "zstd":
+				zr, _ := zstd.NewReader(w.Body)
+				defer zr.Close()
+				body = zr
+			}
+
+			b, err := io.ReadAll(body)
+			if err != nil || string(b) != tt.body {
+				t.Errorf("Compress() body = %q, error = %v, want %q", b, err, tt.body)
+			}
+			if wantETag := `"abc"`; tt.wantEncoding != "" && w.Header().Get("ETag") != "W/"+wantETag {
+				t.Errorf("Compress() ETag = %q, want weak ETag", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestCompress_status(t *testing.T) {
+	h := Compress(WithCompressMinSize(1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("Compress() status = %d, Content-Encoding = %q, body = %q", w.Code, w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
+
+func TestCompress_panic(t *testing.T) {
+	newTestFlow("compress")
+	h := Recover(WithRecoverFlow("compress"))(Compress()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"partial":`)
+		panic("boom")
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "partial") {
+		t.Errorf("Compress() after panic status = %d, body = %q, want problem response", w.Code, w.Body.String())
+	}
+}
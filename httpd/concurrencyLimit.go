@@ -0,0 +1,62 @@
+package httpd
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimit limits the number of requests served at the same time to limit. When the limit is reached, requests are rejected with
+// 503 Service Unavailable and a Retry-After header, unless WithLimitQueue allows them to wait for a slot.
+// ConcurrencyLimit panics if the limit is less than 1.
+func ConcurrencyLimit(limit int, opts ...LimitOption) Middleware {
+	if limit < 1 {
+		panic("httpd: concurrency limit must be at least 1")
+	}
+
+	l := newLimitConfig(opts)
+	slots := make(chan struct{}, limit)
+
+	var waiting atomic.Int64
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := l.key(r)
+
+			select {
+			case slots <- struct{}{}:
+			default:
+				if !l.wait(r, slots, &waiting) {
+					l.log(r, "concurrency limit exceeded", false, key, slog.Int("limit", limit), slog.Int64("waiting", waiting.Load()))
+					l.reject(w, r, http.StatusServiceUnavailable, l.queueTimeout, "too many concurrent requests")
+					return
+				}
+			}
+			defer func() { <-slots }()
+
+			l.log(r, "concurrency limit allowed", true, key, slog.Int("inFlight", len(slots)))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// wait queues the request for a slot, and returns false if the queue is full, the queue timeout expires or the request is cancelled.
+func (l *limitConfig) wait(r *http.Request, slots chan struct{}, waiting *atomic.Int64) bool {
+	if waiting.Add(1) > int64(l.queue) {
+		waiting.Add(-1)
+		return false
+	}
+	defer waiting.Add(-1)
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,12 @@
+package httpd
+
+type contextKey int
+
+const (
+	clientIdentityCtxKey contextKey = iota
+	requestIDCtxKey
+	routeCtxKey
+	principalCtxKey
+	securityHeadersCtxKey
+	proxyCtxKey
+)
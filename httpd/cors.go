@@ -0,0 +1,213 @@
+package httpd
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	DefaultCorsHeaders = []string{"Accept", "Authorization", "Content-Type", HeaderRequestID}
+	DefaultCorsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+)
+
+// CorsOption configures the Cors middleware.
+type CorsOption func(c *corsConfig)
+
+type corsConfig struct {
+	origins        []string
+	patterns       []*regexp.Regexp
+	methods        []string
+	headers        []string
+	exposedHeaders []string
+	credentials    bool
+	maxAge         time.Duration
+}
+
+// Cors implements cross-origin resource sharing, allowing browsers to call the handler from the configured origins.
+// Preflight requests are answered directly, so Cors must wrap the Router rather than be added to its routes, which do not match OPTIONS requests.
+// Preflight requests from an origin that is not allowed, or for a method or header that is not allowed, are rejected with 403 Forbidden.
+// Other requests from an origin that is not allowed are passed on without CORS headers, so the browser does not expose the response.
+// Cors panics if credentials are allowed for any origin, as that would let any website make authenticated requests.
+func Cors(opts ...CorsOption) Middleware {
+	c := &corsConfig{
+		methods: DefaultCorsMethods,
+		headers: DefaultCorsHeaders,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.credentials && c.allowAllOrigins() {
+		panic("httpd: cors credentials cannot be allowed for any origin")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !c.allowAllOrigins() {
+				h.Add("Vary", "Origin")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !c.originAllowed(origin) {
+				if preflight {
+					WriteProblem(w, r, NewProblem(http.StatusForbidden, "origin not allowed"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if c.allowAllOrigins() {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if c.credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(c.exposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !slices.Contains(c.methods, method) {
+				WriteProblem(w, r, NewProblem(http.StatusForbidden, "method not allowed"))
+				return
+			}
+
+			requested := headerTokens(r.Header, "Access-Control-Request-Headers")
+			for _, header := range requested {
+				if !slices.ContainsFunc(c.headers, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+					WriteProblem(w, r, NewProblem(http.StatusForbidden, "header not allowed"))
+					return
+				}
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+			if len(requested) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+			}
+			if c.maxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// WithCorsCredentials allows requests with cookies or HTTP authentication from the allowed origins, which cannot include *.
+func WithCorsCredentials() CorsOption {
+	return func(c *corsConfig) {
+		c.credentials = true
+	}
+}
+
+// WithCorsExposedHeaders sets the response headers that are exposed to the browser, besides the CORS-safelisted response headers.
+func WithCorsExposedHeaders(headers ...string) CorsOption {
+	return func(c *corsConfig) {
+		c.exposedHeaders = headers
+	}
+}
+
+// WithCorsHeaders sets the request headers that are allowed, which default to DefaultCorsHeaders.
+func WithCorsHeaders(headers ...string) CorsOption {
+	return func(c *corsConfig) {
+		c.headers = headers
+	}
+}
+
+// WithCorsMaxAge lets browsers cache the result of preflight requests for the duration.
+func WithCorsMaxAge(d time.Duration) CorsOption {
+	return func(c *corsConfig) {
+		c.maxAge = d
+	}
+}
+
+// WithCorsMethods sets the request methods that are allowed, which default to DefaultCorsMethods.
+func WithCorsMethods(methods ...string) CorsOption {
+	return func(c *corsConfig) {
+		c.methods = methods
+	}
+}
+
+// WithCorsOriginPattern allows the origins matching the regular expression, which should be anchored, e.g. ^https://[a-z]+\.example\.com$.
+func WithCorsOriginPattern(pattern *regexp.Regexp) CorsOption {
+	return func(c *corsConfig) {
+		c.patterns = append(c.patterns, pattern)
+	}
+}
+
+// WithCorsOrigins allows the origins, e.g. https://app.example.com. An origin can contain a single wildcard to allow any subdomain,
+// e.g. https://*.example.com, and * allows any origin.
+func WithCorsOrigins(origins ...string) CorsOption {
+	return func(c *corsConfig) {
+		c.origins = append(c.origins, origins...)
+	}
+}
+
+func (c *corsConfig) allowAllOrigins() bool {
+	return slices.Contains(c.origins, "*")
+}
+
+func (c *corsConfig) originAllowed(origin string) bool {
+	for _, allowed := range c.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		prefix, suffix, found := strings.Cut(strings.ToLower(allowed), "*")
+		if !found {
+			continue
+		}
+
+		lower := strings.ToLower(origin)
+		if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+			// The wildcard only matches subdomains, not a path or port
+			if !strings.ContainsAny(lower[len(prefix):len(lower)-len(suffix)], "/:") {
+				return true
+			}
+		}
+	}
+
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTokens returns the values of a comma separated header.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
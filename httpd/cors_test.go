@@ -0,0 +1,101 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCors(t *testing.T) {
+	h := Cors(
+		WithCorsOrigins("https://app.example.com", "https://*.example.org"),
+		WithCorsOriginPattern(regexp.MustCompile(`^http://localhost:\d+$`)),
+		WithCorsCredentials(),
+		WithCorsExposedHeaders(HeaderRequestID),
+		WithCorsMaxAge(10*time.Minute),
+	)(statusHandler(http.StatusOK))
+
+	tests := []struct {
+		name            string
+		method          string
+		header          map[string]string
+		wantStatus      int
+		wantAllowOrigin string
+		wantHeaders     map[string]string
+	}{
+		{name: "same origin", method: http.MethodGet, wantStatus: http.StatusOK},
+		{
+			name:            "exact origin",
+			method:          http.MethodGet,
+			header:          map[string]string{"Origin": "https://app.example.com"},
+			wantStatus:      http.StatusOK,
+			wantAllowOrigin: "https://app.example.com",
+			wantHeaders:     map[string]string{"Access-Control-Allow-Credentials": "true", "Access-Control-Expose-Headers": HeaderRequestID},
+		},
+		{name: "wildcard origin", method: http.MethodGet, header: map[string]string{"Origin": "https://api.example.org"}, wantStatus: http.StatusOK, wantAllowOrigin: "https://api.example.org"},
+		{name: "wildcard without subdomain", method: http.MethodGet, header: map[string]string{"Origin": "https://example.org"}, wantStatus: http.StatusOK},
+		{name: "pattern origin", method: http.MethodGet, header: map[string]string{"Origin": "http://localhost:3000"}, wantStatus: http.StatusOK, wantAllowOrigin: "http://localhost:3000"},
+		{name: "origin not allowed", method: http.MethodGet, header: map[string]string{"Origin": "https://evil.example.com"}, wantStatus: http.StatusOK},
+		{
+			name:            "preflight",
+			method:          http.MethodOptions,
+			header:          map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type, x-request-id"},
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://app.example.com",
+			wantHeaders:     map[string]string{"Access-Control-Allow-Headers": "content-type, x-request-id", "Access-Control-Max-Age": "600"},
+		},
+		{name: "preflight origin not allowed", method: http.MethodOptions, header: map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"}, wantStatus: http.StatusForbidden},
+		{name: "preflight method not allowed", method: http.MethodOptions, header: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "TRACE"}, wantStatus: http.StatusForbidden, wantAllowOrigin: "https://app.example.com"},
+		{name: "preflight header not allowed", method: http.MethodOptions, header: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"}, wantStatus: http.StatusForbidden, wantAllowOrigin: "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Cors() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Cors() Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			for k, v := range tt.wantHeaders {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("Cors() %s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCors_anyOrigin(t *testing.T) {
+	h := Cors(WithCorsOrigins("*"))(statusHandler(http.StatusOK))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://any.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Cors() Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("Cors() Vary = %q, want none", got)
+	}
+}
+
+func TestCors_anyOriginCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Cors() with credentials for any origin did not panic")
+		}
+	}()
+	Cors(WithCorsOrigins("*"), WithCorsCredentials())
+}
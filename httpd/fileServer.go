@@ -0,0 +1,308 @@
+package httpd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+const (
+	DefaultFileServerIndex = "index.html"
+)
+
+var (
+	// precompressedEncodings lists the supported precompressed variants in order of preference.
+	precompressedEncodings = []struct {
+		encoding  string
+		extension string
+	}{
+		{encoding: "br", extension: ".br"},
+		{encoding: "gzip", extension: ".gz"},
+	}
+)
+
+// FileServerOption configures the file server.
+type FileServerOption func(s *fileServer)
+
+// NewFileServer serves the files in fsys, such as an embed.FS narrowed down using fs.Sub.
+//
+// Strong ETags are computed from the file contents when the file server is created. If a file has a precompressed variant next to it,
+// e.g. app.js.br or app.js.gz, the variant is served to clients accepting the encoding. Files with a content hash in their name, such as
+// app.3f2a9c1b.js or app-B4x9Kz1q.js, are cached as immutable; other files must be revalidated by the client.
+// Requests for a directory serve its index file if present, directories are never listed.
+func NewFileServer(fsys fs.FS, opts ...FileServerOption) (http.Handler, error) {
+	s := &fileServer{
+		fsys:   fsys,
+		index:  DefaultFileServerIndex,
+		assets: make(map[string]*asset),
+		hashed: isHashedName,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.load(); err != nil {
+		return nil, oops.In("httpd").Wrap(err)
+	}
+	return s, nil
+}
+
+// WithFileServerImmutable sets the function reporting if the base name of a file contains a content hash, which makes it cacheable forever.
+func WithFileServerImmutable(hashed func(name string) bool) FileServerOption {
+	return func(s *fileServer) {
+		s.hashed = hashed
+	}
+}
+
+// WithFileServerIndex sets the file served for directories, which defaults to index.html.
+func WithFileServerIndex(name string) FileServerOption {
+	return func(s *fileServer) {
+		s.index = name
+	}
+}
+
+// WithFileServerMaxAge lets clients cache files without a content hash in their name for the duration, instead of revalidating them on every use.
+func WithFileServerMaxAge(d time.Duration) FileServerOption {
+	return func(s *fileServer) {
+		s.maxAge = d
+	}
+}
+
+// WithFileServerSpa serves the index file in the root for requests that do not match a file, so a single-page application can handle the
+// route on the client side. Requests for paths with a file extension, such as a missing script, are still answered with 404 Not Found.
+func WithFileServerSpa() FileServerOption {
+	return func(s *fileServer) {
+		s.spa = true
+	}
+}
+
+type fileServer struct {
+	fsys   fs.FS
+	index  string
+	spa    bool
+	maxAge time.Duration
+	hashed func(name string) bool
+	assets map[string]*asset
+}
+
+type asset struct {
+	name        string
+	etag        string
+	contentType string
+	modTime     time.Time
+	immutable   bool
+	variants    map[string]*asset
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		WriteProblem(w, r, NewProblem(http.StatusMethodNotAllowed, ""))
+		return
+	}
+
+	a, ok := s.lookup(r.URL.Path)
+	if !ok {
+		WriteProblem(w, r, NewProblem(http.StatusNotFound, "file not found"))
+		return
+	}
+
+	cacheControl := "no-cache"
+	switch {
+	case a.immutable:
+		cacheControl = "public, max-age=31536000, immutable"
+	case s.maxAge > 0:
+		cacheControl = "public, max-age=" + strconv.Itoa(int(s.maxAge.Seconds()))
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", cacheControl)
+	h.Set("Content-Type", a.contentType)
+	if len(a.variants) > 0 {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	served := a
+	if encoding, variant, found := a.negotiate(r.Header.Get("Accept-Encoding")); found {
+		h.Set("Content-Encoding", encoding)
+		served = variant
+	}
+	h.Set("ETag", served.etag)
+
+	content, err := s.open(served.name)
+	if err != nil {
+		WriteError(w, r, oops.In("httpd").With("file", served.name).Wrap(err))
+		return
+	}
+	defer content.Close()
+	http.ServeContent(w, r, served.name, served.modTime, content)
+}
+
+// lookup returns the asset for the request path, the index file if the path is a directory, or the SPA index if enabled.
+func (s *fileServer) lookup(urlPath string) (*asset, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+
+	if a, ok := s.assets[name]; ok {
+		return a, true
+	}
+
+	if a, ok := s.assets[path.Join(name, s.index)]; ok {
+		return a, true
+	}
+
+	if s.spa && path.Ext(name) == "" {
+		a, ok := s.assets[s.index]
+		return a, ok
+	}
+	return nil, false
+}
+
+// load computes the metadata of all files and links precompressed variants to the file they were compressed from.
+func (s *fileServer) load() error {
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		b, err := fs.ReadFile(s.fsys, name)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(b)
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = http.DetectContentType(b)
+		}
+
+		s.assets[name] = &asset{
+			name:        name,
+			etag:        `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`,
+			contentType: contentType,
+			modTime:     info.ModTime(),
+			immutable:   s.hashed(path.Base(name)),
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, a := range s.assets {
+		for _, e := range precompressedEncodings {
+			if variant, ok := s.assets[name+e.extension]; ok {
+				if a.variants == nil {
+					a.variants = make(map[string]*asset)
+				}
+				a.variants[e.encoding] = variant
+			}
+		}
+	}
+	return nil
+}
+
+// open returns the file contents as an io.ReadSeeker, as required by http.ServeContent. The caller must close it.
+func (s *fileServer) open(name string) (io.ReadSeekCloser, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsc, ok := f.(io.ReadSeekCloser); ok {
+		return rsc, nil
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return nopReadSeekCloser{bytes.NewReader(b)}, nil
+}
+
+// nopReadSeekCloser adds a no-op Close to the contents of files that cannot seek, which are read into memory.
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error {
+	return nil
+}
+
+// negotiate returns the preferred precompressed variant accepted by the client.
+func (a *asset) negotiate(acceptEncoding string) (string, *asset, bool) {
+	if len(a.variants) == 0 || acceptEncoding == "" {
+		return "", nil, false
+	}
+
+	for _, e := range precompressedEncodings {
+		variant, ok := a.variants[e.encoding]
+		if ok && acceptsEncoding(acceptEncoding, e.encoding) {
+			return e.encoding, variant, true
+		}
+	}
+	return "", nil, false
+}
+
+// acceptsEncoding reports if the Accept-Encoding header allows the encoding with a non-zero quality.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != encoding && coding != "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+
+		// An explicit entry for the encoding takes precedence over the wildcard
+		if coding == encoding {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// isHashedName reports if the file name contains a content hash as generated by common bundlers, e.g. app.3f2a9c1b.js or app-B4x9Kz1q.js.
+// The hash must be at least 8 characters long and contain a digit, so words like settings in app-settings.js are not mistaken for a hash.
+func isHashedName(name string) bool {
+	stem := strings.TrimSuffix(name, path.Ext(name))
+
+	i := strings.LastIndexAny(stem, ".-")
+	if i < 0 {
+		return false
+	}
+
+	hash := stem[i+1:]
+	if len(hash) < 8 || !strings.ContainsAny(hash, "0123456789") {
+		return false
+	}
+
+	for _, c := range hash {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package httpd
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewFileServer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":                {Data: []byte("<html>index</html>")},
+		"assets/app.3f2a9c1b.js":    {Data: []byte("console.log('app')")},
+		"assets/app.3f2a9c1b.js.br": {Data: []byte("br")},
+		"assets/app.3f2a9c1b.js.gz": {Data: []byte("gzip")},
+		"assets/app-settings.css":   {Data: []byte("body{}")},
+		"docs/index.html":           {Data: []byte("<html>docs</html>")},
+		"data":                      {Data: []byte("plain text")},
+	}
+
+	h, err := NewFileServer(fsys, WithFileServerSpa())
+	if err != nil {
+		t.Fatalf("NewFileServer() error = %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		acceptEncoding   string
+		wantStatus       int
+		wantBody         string
+		wantContentType  string
+		wantEncoding     string
+		wantCacheControl string
+	}{
+		{name: "root", path: "/", wantStatus: http.StatusOK, wantBody: "<html>index</html>", wantContentType: "text/html; charset=utf-8", wantCacheControl: "no-cache"},
+		{name: "hashed", path: "/assets/app.3f2a9c1b.js", wantStatus: http.StatusOK, wantBody: "console.log('app')", wantContentType: "text/javascript; charset=utf-8", wantCacheControl: "public, max-age=31536000, immutable"},
+		{name: "brotli", path: "/assets/app.3f2a9c1b.js", acceptEncoding: "gzip, br", wantStatus: http.StatusOK, wantBody: "br", wantContentType: "text/javascript; charset=utf-8", wantEncoding: "br"},
+		{name: "gzip", path: "/assets/app.3f2a9c1b.js", acceptEncoding: "gzip, br;q=0", wantStatus: http.StatusOK, wantBody: "gzip", wantContentType: "text/javascript; charset=utf-8", wantEncoding: "gzip"},
+		{name: "not hashed", path: "/assets/app-settings.css", wantStatus: http.StatusOK, wantContentType: "text/css; charset=utf-8", wantCacheControl: "no-cache"},
+		{name: "directory index", path: "/docs/", wantStatus: http.StatusOK, wantBody: "<html>docs</html>"},
+		{name: "directory without index", path: "/assets/", wantStatus: http.StatusOK, wantBody: "<html>index</html>"},
+		{name: "sniffed content type", path: "/data", wantStatus: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{name: "spa route", path: "/users/42", wantStatus: http.StatusOK, wantBody: "<html>index</html>"},
+		{name: "missing file", path: "/assets/missing.js", wantStatus: http.StatusNotFound},
+		{name: "path traversal", path: "/../../etc/app.conf", wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPost, path: "/", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			r := httptest.NewRequest(method, "/", nil)
+			r.URL.Path = tt.path
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("ServeHTTP() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantContentType != "" && w.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("ServeHTTP() Content-Type = %q, want %q", w.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			if w.Header().Get("Content-Encoding") != tt.wantEncoding {
+				t.Errorf("ServeHTTP() Content-Encoding = %q, want %q", w.Header().Get("Content-Encoding"), tt.wantEncoding)
+			}
+			if tt.wantCacheControl != "" && w.Header().Get("Cache-Control") != tt.wantCacheControl {
+				t.Errorf("ServeHTTP() Cache-Control = %q, want %q", w.Header().Get("Cache-Control"), tt.wantCacheControl)
+			}
+		})
+	}
+}
+
+func TestNewFileServer_etag(t *testing.T) {
+	h, err := NewFileServer(fstest.MapFS{"app.js": {Data: []byte("app")}})
+	if err != nil {
+		t.Fatalf("NewFileServer() error = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.js", nil))
+	etag := w.Header().Get("ETag")
+	if len(etag) < 3 || etag[0] != '"' {
+		t.Fatalf("ServeHTTP() ETag = %q, want a strong ETag", etag)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("ServeHTTP() with matching If-None-Match status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+// countingFS counts the files opened through it that have not been closed.
+type countingFS struct {
+	fs.FS
+	open atomic.Int32
+}
+
+func (c *countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(c.FS, name)
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c.open.Add(1)
+	return &countingFile{File: f, fsys: c}, nil
+}
+
+type countingFile struct {
+	fs.File
+	fsys *countingFS
+}
+
+func (f *countingFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f *countingFile) Close() error {
+	f.fsys.open.Add(-1)
+	return f.File.Close()
+}
+
+func TestNewFileServer_closesFiles(t *testing.T) {
+	fsys := &countingFS{FS: fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}}
+	h, err := NewFileServer(fsys)
+	if err != nil {
+		t.Fatalf("NewFileServer() error = %v", err)
+	}
+	fsys.open.Store(0)
+
+	for range 3 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if n := fsys.open.Load(); n != 0 {
+		t.Errorf("ServeHTTP() left %d files open", n)
+	}
+}
+
+func Test_isHashedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "app.3f2a9c1b.js", want: true},
+		{name: "index-B4x9Kz1q.js", want: true},
+		{name: "app-settings.js", want: false},
+		{name: "app.js", want: false},
+		{name: "favicon.ico", want: false},
+		{name: "app.1234.js", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isHashedName(tt.name); got != tt.want {
+				t.Errorf("isHashedName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package httpd
+
+import (
+	"net/http"
+)
+
+// HandlerFunc is a handler that returns an error instead of writing it to the response itself.
+// Returned errors are rendered as problem responses using WriteError.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls f and writes the returned error as problem response. If f already started writing the response, the error is only logged.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := newResponseWriter(w)
+
+	err := f(rw, r)
+	if err == nil {
+		return
+	}
+
+	if rw.status != 0 {
+		logError(r, ProblemFromError(err).Status, err)
+		return
+	}
+	WriteError(rw, r, err)
+}
@@ -2,104 +2,28 @@ package httpd
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
-	"strconv"
 	"time"
-
-	"github.com/samber/oops"
-
-	"github.com/jantytgat/go-kit/slogd"
 )
 
+// RunHttpServer serves h on the listen address and port until the context is cancelled.
+// If a listener for the address was inherited through socket activation, it is used instead of binding a new one.
+// Panics in h are recovered, logged and answered with a problem response. Use NewServer for more control over the server configuration.
 func RunHttpServer(ctx context.Context, log *slog.Logger, listenAddress string, port int, h http.Handler, shutdownTimeout time.Duration) error {
-	s := &http.Server{
-		Addr:    listenAddress + ":" + strconv.Itoa(port),
-		Handler: h}
-
-	oopsErr := oops.FromContext(ctx).In("httpd").With("listenAddress", listenAddress).With("port", port)
-	log.LogAttrs(ctx, slogd.LevelInfo, "starting http server", slog.String("listenAddress", fmt.Sprintf("http://%s", s.Addr)))
-
-	shutdownCtx, shutdownCancel := context.WithCancel(ctx)
-	defer shutdownCancel()
-
-	// Run goroutine to handle graceful shutdown
-	idleConnectionsClosed := make(chan struct{})
-	go shutdown(shutdownCtx, log, s, shutdownTimeout, idleConnectionsClosed)
-
-	var err error
-	if err = s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-		// Error starting or closing listener:
-		log.LogAttrs(ctx, slogd.LevelError, "http server start failed", slog.Any("error", err.Error()))
-		return oopsErr.Wrap(err)
-	}
-
-	// Keep server running until shutdown has completed
-	<-idleConnectionsClosed
-	if errors.Is(err, http.ErrServerClosed) {
-		return nil
-	}
-	return oopsErr.Wrap(err)
+	return NewServer(Recover()(h),
+		WithLogger(log),
+		WithTcpListener(listenAddress, port),
+		WithShutdownTimeout(shutdownTimeout)).Run(ctx)
 }
 
+// RunSocketHttpServer serves h on the unix socket until the context is cancelled. Clients can use HTTP/1.1, or HTTP/2 with prior knowledge.
+// If a listener for the socket path was inherited through socket activation, it is used instead of binding a new one.
+// Panics in h are recovered, logged and answered with a problem response. Use NewServer for more control over the server configuration.
 func RunSocketHttpServer(ctx context.Context, log *slog.Logger, socketPath string, h http.Handler, shutdownTimeout time.Duration) error {
-	s := &http.Server{
-		Handler: h}
-
-	oopsErr := oops.FromContext(ctx).In("httpd").With("listenAddress", s.Addr)
-	log.LogAttrs(ctx, slogd.LevelInfo, "starting http server", slog.String("socket", s.Addr))
-
-	shutdownCtx, shutdownCancel := context.WithCancel(ctx)
-	defer shutdownCancel()
-
-	// Run goroutine to handle graceful shutdown
-	idleConnectionsClosed := make(chan struct{})
-	go shutdown(shutdownCtx, log, s, shutdownTimeout, idleConnectionsClosed)
-
-	var err error
-	var config = new(net.ListenConfig)
-	var socket net.Listener
-
-	if socket, err = config.Listen(ctx, "unix", socketPath); err != nil {
-		log.LogAttrs(ctx, slogd.LevelError, "failed to listen on socket", slog.String("error", err.Error()))
-		return oopsErr.Wrap(err)
-	}
-
-	if err = s.Serve(socket); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		// Error starting or closing listener:
-		log.LogAttrs(ctx, slogd.LevelError, "http server start failed", slog.String("error", err.Error()))
-		return oopsErr.Wrap(err)
-	}
-
-	// Keep server running until shutdown has completed
-	// Keep server running until shutdown has completed
-	<-idleConnectionsClosed
-	if errors.Is(err, http.ErrServerClosed) {
-		return nil
-	}
-	return oopsErr.Wrap(err)
-}
-
-func shutdown(ctx context.Context, log *slog.Logger, s *http.Server, shutdownTimeout time.Duration, idleConnectionsClosed chan struct{}) {
-	log.LogAttrs(ctx, slogd.LevelTrace, "awaiting shutdown signal for http server", slog.String("listenAddress", s.Addr))
-
-	// Wait for context to start shutting down the server
-	<-ctx.Done()
-
-	// When shutdown signal is received, create a new context with the configured shutdown timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
-	defer shutdownCancel()
-
-	log.LogAttrs(shutdownCtx, slogd.LevelTrace, "shutdown signal received for http server", slog.String("listenAddress", s.Addr))
-	time.Sleep(8 * time.Second)
-	// We received an interrupt signal, shut down.
-	if err := s.Shutdown(shutdownCtx); err != nil {
-		// Error from closing listeners, or context timeout:
-		log.LogAttrs(ctx, slogd.LevelTrace, "shutdown for http server failed", slog.String("listenAddress", s.Addr), slog.Any("error", err))
-	}
-	close(idleConnectionsClosed)
-	log.LogAttrs(shutdownCtx, slogd.LevelTrace, "shutdown for http server completed", slog.String("listenAddress", s.Addr))
+	return NewServer(Recover()(h),
+		WithLogger(log),
+		WithUnixListener(socketPath),
+		WithH2c(),
+		WithShutdownTimeout(shutdownTimeout)).Run(ctx)
 }
@@ -0,0 +1,176 @@
+package httpd
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Oudwins/zog"
+	zss "github.com/Oudwins/zog/pkgs/zss/core"
+	"github.com/Oudwins/zog/zconst"
+)
+
+// jsonSchema converts a zog schema to a JSON Schema (draft 2020-12, as used by OpenAPI 3.1), using the experimental zog schema
+// serialization. Tests without a JSON Schema equivalent, such as custom tests and transforms, are left out.
+//
+// The type is the Go type the schema validates. Properties are named by fieldName after the struct fields validated by the shape keys,
+// fields named "" are left out, and numbers bound to integer fields are integers. Without a type, properties are named after the shape keys.
+func jsonSchema(s zog.ZSSSerializable, t reflect.Type, fieldName func(reflect.StructField) string) map[string]any {
+	return zssToJsonSchema(zog.EXPERIMENTAL_TO_ZSS(s).Root, t, fieldName)
+}
+
+func zssToJsonSchema(s *zss.ZSSSchema, t reflect.Type, fieldName func(reflect.StructField) string) map[string]any {
+	schema := make(map[string]any)
+	if s == nil {
+		return schema
+	}
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch s.Kind {
+	case zconst.TypeString:
+		schema["type"] = "string"
+	case zconst.TypeNumber:
+		schema["type"] = "number"
+		if t != nil {
+			switch t.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				schema["type"] = "integer"
+			}
+		}
+	case zconst.TypeBool:
+		schema["type"] = "boolean"
+	case zconst.TypeTime:
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+	case zconst.TypeSlice:
+		schema["type"] = "array"
+		if child := zssChild(s); child != nil {
+			var elem reflect.Type
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				elem = t.Elem()
+			}
+			schema["items"] = zssToJsonSchema(child, elem, fieldName)
+		}
+	case zconst.TypeStruct:
+		schema["type"] = "object"
+		properties := make(map[string]any)
+		var required []string
+		for _, child := range s.Childs {
+			for key, property := range child.Shape {
+				name, fieldType := key, reflect.Type(nil)
+				if field, ok := schemaField(t, key); ok {
+					if name = fieldName(field); name == "" {
+						continue
+					}
+					fieldType = field.Type
+				}
+
+				properties[name] = zssToJsonSchema(&property, fieldType, fieldName)
+				if property.Required != nil {
+					required = append(required, name)
+				}
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			slices.Sort(required)
+			schema["required"] = required
+		}
+	case zconst.TypePtr:
+		// Pointers only affect whether a value is required, which is described by the parent
+		return zssToJsonSchema(zssChild(s), t, fieldName)
+	}
+
+	if s.DefaultValue != nil {
+		schema["default"] = s.DefaultValue
+	}
+
+	for _, processor := range s.Processors {
+		if processor.Test != nil {
+			addJsonSchemaTest(schema, s.Kind, processor.Test)
+		}
+	}
+	return schema
+}
+
+// schemaField returns the field of the struct type validated by the shape key of a zog struct schema,
+// which zog looks up by the key with an uppercase first letter.
+func schemaField(t reflect.Type, key string) (reflect.StructField, bool) {
+	if t == nil || t.Kind() != reflect.Struct || key == "" {
+		return reflect.StructField{}, false
+	}
+	return t.FieldByName(strings.ToUpper(key[:1]) + key[1:])
+}
+
+// jsonFieldName returns the name of the field in its JSON encoding, or "" if the field is not encoded.
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// zssChild returns the schema of the elements of a slice or the value of a pointer.
+func zssChild(s *zss.ZSSSchema) *zss.ZSSSchema {
+	for _, child := range s.Childs {
+		if child.Kind == zss.ZSSSchemaChildKindSchema {
+			return child.Schema
+		}
+	}
+	return nil
+}
+
+// addJsonSchemaTest adds the JSON Schema keywords equivalent to a zog test.
+func addJsonSchemaTest(schema map[string]any, kind string, test *zss.ZSSTest) {
+	param := test.Params[test.ID]
+
+	switch test.ID {
+	case zconst.IssueCodeMin, zconst.IssueCodeMax, zconst.IssueCodeLen:
+		keywords := map[string][2]string{
+			zconst.TypeString: {"minLength", "maxLength"},
+			zconst.TypeSlice:  {"minItems", "maxItems"},
+		}[kind]
+		if keywords[0] == "" {
+			return
+		}
+		if test.ID != zconst.IssueCodeMax {
+			schema[keywords[0]] = param
+		}
+		if test.ID != zconst.IssueCodeMin {
+			schema[keywords[1]] = param
+		}
+	case zconst.IssueCodeGTE:
+		schema["minimum"] = param
+	case zconst.IssueCodeGT:
+		schema["exclusiveMinimum"] = param
+	case zconst.IssueCodeLTE:
+		schema["maximum"] = param
+	case zconst.IssueCodeLT:
+		schema["exclusiveMaximum"] = param
+	case zconst.IssueCodeEQ:
+		schema["const"] = param
+	case zconst.IssueCodeOneOf:
+		schema["enum"] = param
+	case zconst.IssueCodeMatch:
+		schema["pattern"] = param
+	case zconst.IssueCodeEmail:
+		schema["format"] = "email"
+	case zconst.IssueCodeURL:
+		schema["format"] = "uri"
+	case zconst.IssueCodeUUID:
+		schema["format"] = "uuid"
+	case zconst.IssueCodeTrue:
+		schema["const"] = true
+	case zconst.IssueCodeFalse:
+		schema["const"] = false
+	}
+}
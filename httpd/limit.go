@@ -0,0 +1,141 @@
+package httpd
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+// KeyFunc returns the key a request is limited by.
+type KeyFunc func(r *http.Request) string
+
+// KeyByClientIdentity limits requests by the subject of the verified client certificate, falling back to the client IP address.
+func KeyByClientIdentity() KeyFunc {
+	return func(r *http.Request) string {
+		if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+			return identity.Subject
+		}
+		return clientIP(r)
+	}
+}
+
+// KeyByPrincipal limits requests by the subject of the principal resolved by Authenticate, falling back to KeyByClientIdentity.
+func KeyByPrincipal() KeyFunc {
+	fallback := KeyByClientIdentity()
+	return func(r *http.Request) string {
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			return p.Method + ":" + p.Subject
+		}
+		return fallback(r)
+	}
+}
+
+// KeyByHeader limits requests by the value of the header, falling back to the client IP address if the header is not set.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+		return clientIP(r)
+	}
+}
+
+// KeyByIP limits requests by the client IP address.
+func KeyByIP() KeyFunc {
+	return clientIP
+}
+
+// LimitOption configures the RateLimit and ConcurrencyLimit middleware.
+type LimitOption func(l *limitConfig)
+
+type limitConfig struct {
+	key           KeyFunc
+	flow          string
+	allowedLevel  slog.Level
+	rejectedLevel slog.Level
+	queue         int
+	queueTimeout  time.Duration
+}
+
+func newLimitConfig(opts []LimitOption) *limitConfig {
+	l := &limitConfig{
+		key:           KeyByIP(),
+		allowedLevel:  slogd.LevelTrace,
+		rejectedLevel: slogd.LevelWarn,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// WithLimitFlow logs the limit decisions to the named slogd flow.
+func WithLimitFlow(name string) LimitOption {
+	return func(l *limitConfig) {
+		l.flow = name
+	}
+}
+
+// WithLimitKey sets the function returning the key requests are limited by. It defaults to KeyByIP.
+// For ConcurrencyLimit, the key is only used for logging, as the limit applies to all requests.
+func WithLimitKey(key KeyFunc) LimitOption {
+	return func(l *limitConfig) {
+		l.key = key
+	}
+}
+
+// WithLimitLevel sets the log levels for allowed and rejected requests, which default to trace and warn.
+func WithLimitLevel(allowed, rejected slog.Level) LimitOption {
+	return func(l *limitConfig) {
+		l.allowedLevel = allowed
+		l.rejectedLevel = rejected
+	}
+}
+
+// WithLimitQueue lets up to n requests wait at most timeout for a slot when the ConcurrencyLimit is reached, instead of rejecting them.
+func WithLimitQueue(n int, timeout time.Duration) LimitOption {
+	return func(l *limitConfig) {
+		l.queue = n
+		l.queueTimeout = timeout
+	}
+}
+
+func (l *limitConfig) log(r *http.Request, msg string, allowed bool, key string, attrs ...slog.Attr) {
+	level := l.allowedLevel
+	if !allowed {
+		level = l.rejectedLevel
+	}
+
+	ctx := r.Context()
+	logger := flowLogger(ctx, l.flow)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append(attrs,
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("key", key),
+		slog.Bool("allowed", allowed))
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// reject responds with the status and a Retry-After header.
+func (l *limitConfig) reject(w http.ResponseWriter, r *http.Request, status int, retryAfter time.Duration, detail string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+	WriteProblem(w, r, NewProblem(status, detail))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
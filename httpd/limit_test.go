@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_tokenBuckets_take(t *testing.T) {
+	start := time.Now()
+	b := newTokenBuckets(2, 2)
+
+	tests := []struct {
+		name           string
+		key            string
+		at             time.Duration
+		want           bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "first token", key: "a", want: true},
+		{name: "burst token", key: "a", want: true},
+		{name: "empty bucket", key: "a", want: false, wantRetryAfter: 500 * time.Millisecond},
+		{name: "other key", key: "b", want: true},
+		{name: "refilled token", key: "a", at: 500 * time.Millisecond, want: true},
+		{name: "empty again", key: "a", at: 600 * time.Millisecond, want: false, wantRetryAfter: 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, retryAfter := b.take(tt.key, start.Add(tt.at))
+			if got != tt.want || (retryAfter-tt.wantRetryAfter).Abs() > time.Millisecond {
+				t.Errorf("take() = %v, %v, want %v, %v", got, retryAfter, tt.want, tt.wantRetryAfter)
+			}
+		})
+	}
+
+	// Full buckets are removed after the sweep interval
+	b.take("c", start.Add(2*rateLimitSweepInterval))
+	if len(b.buckets) != 1 {
+		t.Errorf("sweep() kept %d buckets, want 1", len(b.buckets))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := RateLimit(0.001, 1, WithLimitKey(KeyByHeader("X-Api-Key")))(statusHandler(http.StatusOK))
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantStatus int
+	}{
+		{name: "first request", apiKey: "a", wantStatus: http.StatusOK},
+		{name: "limited", apiKey: "a", wantStatus: http.StatusTooManyRequests},
+		{name: "other key", apiKey: "b", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Api-Key", tt.apiKey)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("RateLimit() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1000" {
+				t.Errorf("RateLimit() Retry-After = %q, want %q", w.Header().Get("Retry-After"), "1000")
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []LimitOption
+		release    time.Duration
+		wantStatus int
+	}{
+		{name: "rejected", wantStatus: http.StatusServiceUnavailable},
+		{name: "queued", opts: []LimitOption{WithLimitQueue(1, time.Second)}, release: 50 * time.Millisecond, wantStatus: http.StatusOK},
+		{name: "queue timeout", opts: []LimitOption{WithLimitQueue(1, 50*time.Millisecond)}, release: time.Second, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := ConcurrencyLimit(1, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/block" {
+					close(started)
+					<-release
+				}
+			}))
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+			}()
+			<-started
+
+			timer := time.AfterFunc(tt.release, func() { close(release) })
+			defer func() {
+				if timer.Stop() {
+					close(release)
+				}
+				wg.Wait()
+			}()
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("ConcurrencyLimit() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+				t.Errorf("ConcurrencyLimit() Retry-After not set")
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimit_invalid(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		t.Run(strconv.Itoa(limit), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ConcurrencyLimit(%d) did not panic", limit)
+				}
+			}()
+			ConcurrencyLimit(limit)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	ContentTypeNdjson         = "application/x-ndjson"
+	DefaultLogStreamBuffer    = 256
+	DefaultLogStreamHeartbeat = 15 * time.Second
+)
+
+// LogStreamOption configures a LogStream.
+type LogStreamOption func(s *LogStream)
+
+// NewLogStream creates a LogStream for the records of the tee.
+func NewLogStream(tee *slogd.Tee, opts ...LogStreamOption) *LogStream {
+	s := &LogStream{
+		tee:       tee,
+		buffer:    DefaultLogStreamBuffer,
+		heartbeat: DefaultLogStreamHeartbeat,
+		done:      make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// LogStream streams the log records of a slogd.Tee to the client, as server-sent events if the client accepts text/event-stream,
+// or as newline delimited JSON otherwise. It must only be served on a protected listener, such as the admin socket.
+//
+// The records are filtered using the query string:
+//
+//	level=WARN              records of at least the level
+//	flow=http&flow=app      records of any of the flows
+//	attr=method=GET         records with the attribute value, repeat to require multiple attributes
+//	tail=100                the number of buffered records to send first, all by default
+//	follow=false            end the stream after the buffered records
+//
+// Clients reconnecting with a Last-Event-ID header only receive the buffered records they missed.
+// Register Shutdown using WithShutdownHook, so the streams are ended when the server shuts down.
+type LogStream struct {
+	tee       *slogd.Tee
+	buffer    int
+	heartbeat time.Duration
+
+	mux      sync.Mutex
+	closed   bool
+	done     chan struct{}
+	handlers sync.WaitGroup
+}
+
+// WithLogStreamBuffer sets the number of records buffered for each client. Records are dropped for clients that do not keep up.
+func WithLogStreamBuffer(n int) LogStreamOption {
+	return func(s *LogStream) {
+		s.buffer = n
+	}
+}
+
+// WithLogStreamHeartbeat sets the interval of the comments sent to keep idle server-sent event streams open. Zero disables heartbeats.
+func WithLogStreamHeartbeat(d time.Duration) LogStreamOption {
+	return func(s *LogStream) {
+		s.heartbeat = d
+	}
+}
+
+// logStreamRecord is the JSON representation of a record.
+type logStreamRecord struct {
+	Seq     uint64         `json:"seq"`
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Flow    string         `json:"flow"`
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// logStreamFilter selects the records sent to a client.
+type logStreamFilter struct {
+	level   slog.Level
+	flows   []string
+	attrs   map[string]string
+	afterID uint64
+}
+
+func (s *LogStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		WriteProblem(w, r, NewProblem(http.StatusServiceUnavailable, "server is shutting down"))
+		return
+	}
+	s.handlers.Add(1)
+	s.mux.Unlock()
+	defer s.handlers.Done()
+
+	query := r.URL.Query()
+	filter, err := newLogStreamFilter(r)
+	if err != nil {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	tail := -1
+	if v := query.Get("tail"); v != "" {
+		if tail, err = strconv.Atoi(v); err != nil || tail < 0 {
+			WriteProblem(w, r, NewProblem(http.StatusBadRequest, "invalid tail"))
+			return
+		}
+	}
+	follow := query.Get("follow") != "false"
+
+	sse := strings.Contains(r.Header.Get("Accept"), ContentTypeEventStream)
+
+	records, ch := s.tee.Subscribe(ctx, s.buffer)
+	records = slices.DeleteFunc(records, func(record slogd.TeeRecord) bool {
+		return !filter.matches(record)
+	})
+	if tail >= 0 && len(records) > tail {
+		records = records[len(records)-tail:]
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	if sse {
+		h.Set("Content-Type", ContentTypeEventStream)
+	} else {
+		h.Set("Content-Type", ContentTypeNdjson)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		writeLogStreamRecord(&buf, record, sse)
+	}
+	if !flushEvents(w, rc, &buf) || !follow {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if sse && s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case record, ok := <-ch:
+			if !ok {
+				return
+			}
+			if filter.matches(record) {
+				writeLogStreamRecord(&buf, record, sse)
+			}
+		case <-heartbeat:
+			buf.WriteString(": heartbeat\n\n")
+		}
+
+		if !flushEvents(w, rc, &buf) {
+			return
+		}
+	}
+}
+
+// Shutdown ends all streams, and waits until the handlers have returned or the context expires.
+func (s *LogStream) Shutdown(ctx context.Context) error {
+	s.mux.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newLogStreamFilter(r *http.Request) (logStreamFilter, error) {
+	query := r.URL.Query()
+	filter := logStreamFilter{
+		level: slogd.LevelTrace,
+		flows: query["flow"],
+		attrs: make(map[string]string),
+	}
+
+	if v := query.Get("level"); v != "" {
+		level, ok := slogd.LookupLevel(v)
+		if !ok {
+			return filter, fmt.Errorf("unknown level %q", v)
+		}
+		filter.level = level
+	}
+
+	for _, attr := range query["attr"] {
+		key, value, found := strings.Cut(attr, "=")
+		if !found || key == "" {
+			return filter, fmt.Errorf("invalid attribute filter %q, expected key=value", attr)
+		}
+		filter.attrs[key] = value
+	}
+
+	if v := r.Header.Get(HeaderLastEventID); v != "" {
+		filter.afterID, _ = strconv.ParseUint(v, 10, 64)
+	}
+	return filter, nil
+}
+
+func (f logStreamFilter) matches(record slogd.TeeRecord) bool {
+	if record.Seq <= f.afterID || record.Level < f.level {
+		return false
+	}
+
+	if len(f.flows) > 0 && !slices.Contains(f.flows, record.Flow) {
+		return false
+	}
+
+	for k, v := range f.attrs {
+		if value, ok := record.Attrs[k]; !ok || fmt.Sprint(value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLogStreamRecord writes the record as a server-sent event, or as a line of JSON.
+func writeLogStreamRecord(buf *bytes.Buffer, record slogd.TeeRecord, sse bool) {
+	b, err := json.Marshal(logStreamRecord{
+		Seq:     record.Seq,
+		Time:    record.Time,
+		Level:   slogd.GetLevelName(record.Level),
+		Flow:    record.Flow,
+		Message: record.Message,
+		Attrs:   record.Attrs,
+	})
+	if err != nil {
+		// Attribute values that cannot be represented in JSON are dropped, the record itself is still sent
+		b, _ = json.Marshal(logStreamRecord{
+			Seq:     record.Seq,
+			Time:    record.Time,
+			Level:   slogd.GetLevelName(record.Level),
+			Flow:    record.Flow,
+			Message: record.Message,
+		})
+	}
+
+	if !sse {
+		buf.Write(b)
+		buf.WriteByte('\n')
+		return
+	}
+	Event{ID: strconv.FormatUint(record.Seq, 10), Event: "log", Data: string(b)}.writeTo(buf)
+}
@@ -0,0 +1,131 @@
+package httpd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+func TestLogStream(t *testing.T) {
+	tee := slogd.NewTee(10)
+	appLog := slog.New(slogd.NewTeeHandler("tee", tee, "app", slogd.LevelDebug).Handler())
+	httpLog := slog.New(slogd.NewTeeHandler("tee", tee, "http", slogd.LevelDebug).Handler())
+
+	appLog.Debug("starting", slog.Int("workers", 4))
+	httpLog.WithGroup("request").Info("request", slog.String("method", "GET"))
+	httpLog.WithGroup("request").Warn("request", slog.String("method", "POST"))
+
+	s := NewLogStream(tee)
+
+	tests := []struct {
+		name     string
+		query    string
+		want     []string
+		wantCode int
+	}{
+		{name: "all", query: "follow=false", want: []string{"starting", "request", "request"}, wantCode: http.StatusOK},
+		{name: "level", query: "follow=false&level=info", want: []string{"request", "request"}, wantCode: http.StatusOK},
+		{name: "flow", query: "follow=false&flow=app", want: []string{"starting"}, wantCode: http.StatusOK},
+		{name: "attribute", query: "follow=false&attr=request.method=POST", want: []string{"request"}, wantCode: http.StatusOK},
+		{name: "tail", query: "follow=false&tail=1", want: []string{"request"}, wantCode: http.StatusOK},
+		{name: "unknown level", query: "level=loud", wantCode: http.StatusBadRequest},
+		{name: "invalid attribute", query: "attr=method", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+				var record logStreamRecord
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("ServeHTTP() invalid record %q: %v", line, err)
+				}
+				got = append(got, record.Message)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ServeHTTP() records = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogStream_follow(t *testing.T) {
+	tee := slogd.NewTee(10)
+	log := slog.New(slogd.NewTeeHandler("tee", tee, "app", slogd.LevelInfo).Handler())
+	log.Info("before")
+
+	s := NewLogStream(tee)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?level=info", nil)
+	req.Header.Set("Accept", ContentTypeEventStream)
+	req.Header.Set(HeaderLastEventID, "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request log stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	log.Debug("filtered")
+	log.Info("after", slog.String("error", "failed"))
+
+	if got := readEvents(t, bufio.NewReader(resp.Body), 1); !strings.HasPrefix(got[0], "id: 2\nevent: log\ndata: ") || !strings.Contains(got[0], `"msg":"after"`) {
+		t.Errorf("log stream event = %q, want the record logged after subscribing", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestLogStream_Shutdown(t *testing.T) {
+	s := NewLogStream(slogd.NewTee(10))
+
+	// Streams starting while the log stream shuts down are either waited for or rejected
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusOK && w.Code != http.StatusServiceUnavailable {
+				t.Errorf("ServeHTTP() status = %d, want %d or %d", w.Code, http.StatusOK, http.StatusServiceUnavailable)
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() after Shutdown() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
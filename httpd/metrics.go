@@ -0,0 +1,107 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jantytgat/go-kit/metrics"
+)
+
+// MetricsOption configures the Metrics middleware.
+type MetricsOption func(m *metricsConfig)
+
+// Metrics records the number of requests by method, route and status, the request duration by method and route, and the number of
+// requests in flight. The route is the pattern of the matched http.ServeMux or Router route, so the number of series stays bounded;
+// requests that did not match a route are recorded as "unmatched".
+// Metrics are recorded in metrics.Default, use metrics.Handler to expose them.
+func Metrics(opts ...MetricsOption) Middleware {
+	m := &metricsConfig{
+		registry: metrics.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	requests := m.registry.Counter("http_requests_total", "Total number of HTTP requests.", "method", "route", "status")
+	duration := m.registry.Histogram("http_request_duration_seconds", "Duration of HTTP requests in seconds.", metrics.DefaultBuckets, "method", "route")
+	inFlight := m.registry.Gauge("http_requests_in_flight", "Number of HTTP requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			rt := &route{}
+			rw := newResponseWriter(w)
+			req := r.WithContext(context.WithValue(r.Context(), routeCtxKey, rt))
+			next.ServeHTTP(rw, req)
+
+			// A http.ServeMux wrapped directly by the middleware sets the pattern on the request itself
+			if rt.pattern == "" {
+				rt.pattern = req.Pattern
+			}
+
+			method := metricsMethod(r.Method)
+			pattern := rt.path()
+			requests.Inc(method, pattern, strconv.Itoa(rw.Status()))
+			duration.Observe(time.Since(start).Seconds(), method, pattern)
+		})
+	}
+}
+
+type metricsConfig struct {
+	registry *metrics.Registry
+}
+
+// WithMetricsRegistry records the metrics in the registry instead of metrics.Default.
+func WithMetricsRegistry(r *metrics.Registry) MetricsOption {
+	return func(m *metricsConfig) {
+		m.registry = r
+	}
+}
+
+// metricsMethod limits the method label to the standard methods.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// route receives the pattern of the matched route from handlers further down the chain, which may see a copy of the request.
+type route struct {
+	pattern string
+}
+
+func (rt *route) path() string {
+	if rt.pattern == "" {
+		return "unmatched"
+	}
+
+	// Strip the method and host from patterns such as "GET example.com/items/{id}"
+	pattern := rt.pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " ")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// recordRoute stores the pattern of the matched route for the Metrics middleware.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rt, ok := r.Context().Value(routeCtxKey).(*route); ok {
+			rt.pattern = r.Pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
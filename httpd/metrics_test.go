@@ -0,0 +1,55 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jantytgat/go-kit/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	rt := NewRouter(RequestID())
+	rt.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /upload", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		method     string
+		path       string
+		wantRoute  string
+		wantStatus string
+	}{
+		{name: "router", handler: rt, method: http.MethodGet, path: "/items/1", wantRoute: "/items/{id}", wantStatus: "200"},
+		{name: "router unmatched", handler: rt, method: http.MethodGet, path: "/unknown", wantRoute: "unmatched", wantStatus: "404"},
+		{name: "serve mux", handler: mux, method: http.MethodPost, path: "/upload", wantRoute: "/upload", wantStatus: "201"},
+		{name: "custom method", handler: mux, method: "PURGE", path: "/upload", wantRoute: "unmatched", wantStatus: "405"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Metrics(WithMetricsRegistry(registry))(tt.handler)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			method := metricsMethod(tt.method)
+			if got := registry.Counter("http_requests_total", "Total number of HTTP requests.", "method", "route", "status").Value(method, tt.wantRoute, tt.wantStatus); got != 1 {
+				t.Errorf("http_requests_total{%s,%s,%s} = %v, want 1", method, tt.wantRoute, tt.wantStatus, got)
+			}
+			if got := registry.Histogram("http_request_duration_seconds", "Duration of HTTP requests in seconds.", metrics.DefaultBuckets, "method", "route").Count(method, tt.wantRoute); got == 0 {
+				t.Errorf("http_request_duration_seconds{%s,%s} count = %v, want > 0", method, tt.wantRoute, got)
+			}
+		})
+	}
+
+	if got := registry.Gauge("http_requests_in_flight", "Number of HTTP requests currently being served.").Value(); got != 0 {
+		t.Errorf("http_requests_in_flight = %v, want 0", got)
+	}
+}
@@ -0,0 +1,28 @@
+package httpd
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+// Middleware wraps a http.Handler with additional behaviour.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps the handler with the middleware. The first middleware is the outermost and sees the request first.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// flowLogger returns the logger for the named flow of the slogd LogSet in the context, or the default logger if name is empty.
+func flowLogger(ctx context.Context, name string) *slog.Logger {
+	if name == "" {
+		return slogd.FromContext(ctx).DefaultLogger()
+	}
+	return slogd.FromContext(ctx).Logger(name)
+}
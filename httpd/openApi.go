@@ -0,0 +1,260 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Oudwins/zog"
+	"github.com/samber/oops"
+)
+
+const (
+	OpenApiVersion = "3.1.0"
+)
+
+// OpenApiOption configures an OpenApi document.
+type OpenApiOption func(o *OpenApi)
+
+// NewOpenApi creates an OpenAPI 3.1 document describing the routes of the router. The document is generated from the routes
+// registered at the time it is served or marshalled, so it can be created before all routes are registered.
+func NewOpenApi(rt *Router, title string, opts ...OpenApiOption) *OpenApi {
+	o := &OpenApi{
+		router: rt,
+		title:  title,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.version == "" {
+		o.version = "0.0.0"
+	}
+	return o
+}
+
+// OpenApi is an OpenAPI document generated from the routes of a Router and the zog schemas they are described with.
+// Serve it as a handler, or write it from a command using application.NewDocumentCommand.
+//
+// Routes registered without a method are not included. Path parameters are described as required strings, and every operation
+// includes a default response with the problem returned by WriteError. Schema properties are named after the fields of the types
+// passed with the RouteOptions, using their json tags for bodies and responses, and the names BindQuery uses for query parameters.
+type OpenApi struct {
+	router      *Router
+	title       string
+	version     string
+	description string
+	servers     []string
+}
+
+// WithOpenApiDescription sets the description of the API, which may contain CommonMark.
+func WithOpenApiDescription(description string) OpenApiOption {
+	return func(o *OpenApi) {
+		o.description = description
+	}
+}
+
+// WithOpenApiServers sets the URLs the API is served on, e.g. https://api.example.com/v1.
+func WithOpenApiServers(urls ...string) OpenApiOption {
+	return func(o *OpenApi) {
+		o.servers = urls
+	}
+}
+
+// WithOpenApiVersion sets the version of the API, e.g. application.GetVersion().Full, which defaults to 0.0.0.
+func WithOpenApiVersion(version string) OpenApiOption {
+	return func(o *OpenApi) {
+		o.version = version
+	}
+}
+
+func (o *OpenApi) MarshalJSON() ([]byte, error) {
+	info := map[string]any{
+		"title":   o.title,
+		"version": o.version,
+	}
+	if o.description != "" {
+		info["description"] = o.description
+	}
+
+	doc := map[string]any{
+		"openapi": OpenApiVersion,
+		"info":    info,
+		"paths":   o.paths(),
+	}
+
+	if len(o.servers) > 0 {
+		servers := make([]map[string]any, len(o.servers))
+		for i, url := range o.servers {
+			servers[i] = map[string]any{"url": url}
+		}
+		doc["servers"] = servers
+	}
+	return json.Marshal(doc)
+}
+
+func (o *OpenApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		WriteError(w, r, oops.FromContext(r.Context()).In("httpd").Wrap(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func (o *OpenApi) paths() map[string]any {
+	paths := make(map[string]any)
+	for _, route := range o.router.Routes() {
+		if route.Method == "" {
+			continue
+		}
+
+		path, params := openApiPath(route.Pattern)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = openApiOperation(route, params)
+	}
+	return paths
+}
+
+func openApiOperation(route Route, pathParams []string) map[string]any {
+	op := make(map[string]any)
+	if route.OperationID != "" {
+		op["operationId"] = route.OperationID
+	}
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if len(route.Tags) > 0 {
+		op["tags"] = route.Tags
+	}
+	if route.Deprecated {
+		op["deprecated"] = true
+	}
+
+	var params []map[string]any
+	for _, name := range pathParams {
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	if route.Query != nil {
+		params = append(params, openApiQueryParams(route.Query, route.QueryType)...)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.RequestBody != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": jsonSchema(route.RequestBody, route.RequestBodyType, jsonFieldName)}},
+		}
+	}
+
+	responses := map[string]any{
+		"default": map[string]any{
+			"description": "Problem",
+			"content":     map[string]any{ContentTypeProblem: map[string]any{"schema": problemJsonSchema}},
+		},
+	}
+	for status, resp := range route.Responses {
+		response := map[string]any{"description": resp.Description}
+		if resp.Schema != nil {
+			response["content"] = map[string]any{"application/json": map[string]any{"schema": jsonSchema(resp.Schema, resp.Type, jsonFieldName)}}
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	op["responses"] = responses
+	return op
+}
+
+// openApiQueryParams describes the properties of the query schema as query parameters.
+func openApiQueryParams(schema *zog.StructSchema, t reflect.Type) []map[string]any {
+	s := jsonSchema(schema, t, bindFieldName)
+	properties, _ := s["properties"].(map[string]any)
+	required, _ := s["required"].([]string)
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	params := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		param := map[string]any{
+			"name":   name,
+			"in":     "query",
+			"schema": properties[name],
+		}
+		if slices.Contains(required, name) {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// openApiPath converts a http.ServeMux pattern to an OpenAPI path, returning the names of its path parameters.
+// The host of the pattern is dropped, wildcards matching the remainder of the path become regular parameters and {$} is removed.
+func openApiPath(pattern string) (string, []string) {
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	pattern = strings.TrimSuffix(pattern, "{$}")
+
+	var params []string
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		segments[i] = "{" + name + "}"
+		params = append(params, name)
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// problemJsonSchema describes the problem responses written by WriteProblem.
+var problemJsonSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"type":      map[string]any{"type": "string"},
+		"title":     map[string]any{"type": "string"},
+		"status":    map[string]any{"type": "integer"},
+		"detail":    map[string]any{"type": "string"},
+		"instance":  map[string]any{"type": "string"},
+		"code":      map[string]any{"type": "string"},
+		"requestId": map[string]any{"type": "string"},
+		"issues": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"field":   map[string]any{"type": "string"},
+					"message": map[string]any{"type": "string"},
+					"code":    map[string]any{"type": "string"},
+				},
+			},
+		},
+	},
+	"required": []string{"title", "status"},
+}
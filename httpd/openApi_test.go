@@ -0,0 +1,161 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	z "github.com/Oudwins/zog"
+)
+
+type openApiTestUser struct {
+	UserName string `json:"user_name"`
+	Age      int
+	Tags     []string `json:"tags,omitempty"`
+	Password string   `json:"-"`
+}
+
+func TestJsonSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema z.ZSSSerializable
+		typ    reflect.Type
+		want   string
+	}{
+		{name: "string", schema: z.String().Min(2).Max(10).Email(), want: `{"format":"email","maxLength":10,"minLength":2,"type":"string"}`},
+		{name: "string enum", schema: z.String().OneOf([]string{"a", "b"}), want: `{"enum":["a","b"],"type":"string"}`},
+		{name: "string pattern", schema: z.String().Match(regexp.MustCompile("^[a-z]+$")), want: `{"pattern":"^[a-z]+$","type":"string"}`},
+		{name: "number", schema: z.Int().GTE(1).LT(100), want: `{"exclusiveMaximum":100,"minimum":1,"type":"number"}`},
+		{name: "integer", schema: z.Int().GTE(1), typ: reflect.TypeFor[int](), want: `{"minimum":1,"type":"integer"}`},
+		{name: "integer pointer", schema: z.Ptr(z.Uint()), typ: reflect.TypeFor[*uint](), want: `{"type":"integer"}`},
+		{name: "float", schema: z.Float64(), typ: reflect.TypeFor[float64](), want: `{"type":"number"}`},
+		{name: "default", schema: z.Float64().Default(1.5), want: `{"default":1.5,"type":"number"}`},
+		{name: "time", schema: z.Time(), want: `{"format":"date-time","type":"string"}`},
+		{name: "slice", schema: z.Slice(z.String().Len(3)).Min(1), want: `{"items":{"maxLength":3,"minLength":3,"type":"string"},"minItems":1,"type":"array"}`},
+		{name: "pointer", schema: z.Ptr(z.Bool()), want: `{"type":"boolean"}`},
+		{
+			name:   "struct",
+			schema: z.Struct(z.Shape{"name": z.String().Required(), "age": z.Int(), "address": z.Struct(z.Shape{"city": z.String().Required()})}),
+			want:   `{"properties":{"address":{"properties":{"city":{"type":"string"}},"required":["city"],"type":"object"},"age":{"type":"number"},"name":{"type":"string"}},"required":["name"],"type":"object"}`,
+		},
+		{
+			name:   "struct type",
+			schema: z.Slice(z.Struct(z.Shape{"userName": z.String().Required(), "age": z.Int(), "tags": z.Slice(z.String()), "password": z.String()})),
+			typ:    reflect.TypeFor[[]*openApiTestUser](),
+			want:   `{"items":{"properties":{"Age":{"type":"integer"},"tags":{"items":{"type":"string"},"type":"array"},"user_name":{"type":"string"}},"required":["user_name"],"type":"object"},"type":"array"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(jsonSchema(tt.schema, tt.typ, jsonFieldName))
+			if err != nil {
+				t.Fatalf("jsonSchema() marshal error = %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("jsonSchema() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenApi(t *testing.T) {
+	type item struct {
+		ItemName string `json:"name"`
+	}
+	type query struct {
+		Limit  int
+		Search string `form:"q"`
+	}
+
+	itemSchema := z.Struct(z.Shape{"itemName": z.String().Required()})
+	noop := func(w http.ResponseWriter, r *http.Request) error { return nil }
+
+	rt := NewRouter()
+	rt.Handle("", "/static/", http.NotFoundHandler())
+	rt.Get("/{$}", noop, WithRouteSummary("Index"))
+
+	api := rt.Group("/api")
+	api.Get("/items", noop,
+		WithRouteOperationID("listItems"),
+		WithRouteTags("items"),
+		WithRouteQuery(z.Struct(z.Shape{"limit": z.Int().GTE(1), "search": z.String().Required()}), query{}),
+		WithRouteResponse(http.StatusOK, "Items", z.Slice(itemSchema), []item{}))
+	api.Post("/items", noop, WithRouteRequestBody(itemSchema, item{}), WithRouteResponse(http.StatusCreated, "Created", itemSchema, item{}))
+	api.Get("/items/{id}/files/{path...}", noop, WithRouteDeprecated())
+
+	o := NewOpenApi(rt, "Items", WithOpenApiVersion("1.2.3"), WithOpenApiServers("https://api.example.com"))
+
+	w := httptest.NewRecorder()
+	o.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("ServeHTTP() Content-Type = %s, want application/json", ct)
+	}
+
+	var doc struct {
+		OpenApi string                               `json:"openapi"`
+		Info    map[string]string                    `json:"info"`
+		Servers []map[string]string                  `json:"servers"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("ServeHTTP() invalid document: %v", err)
+	}
+
+	if doc.OpenApi != OpenApiVersion || doc.Info["title"] != "Items" || doc.Info["version"] != "1.2.3" || doc.Servers[0]["url"] != "https://api.example.com" {
+		t.Errorf("ServeHTTP() document header = %s %v %v", doc.OpenApi, doc.Info, doc.Servers)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	if want := []string{"/", "/api/items", "/api/items/{id}/files/{path}"}; !slices.Equal(paths, want) {
+		t.Errorf("ServeHTTP() paths = %v, want %v", paths, want)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		op   string
+		key  string
+		want string
+	}{
+		{name: "summary", path: "/", op: "get", key: "summary", want: `"Index"`},
+		{name: "operation id", path: "/api/items", op: "get", key: "operationId", want: `"listItems"`},
+		{name: "tags", path: "/api/items", op: "get", key: "tags", want: `["items"]`},
+		{
+			name: "query parameters", path: "/api/items", op: "get", key: "parameters",
+			want: `[{"in":"query","name":"limit","schema":{"minimum":1,"type":"integer"}},{"in":"query","name":"q","required":true,"schema":{"type":"string"}}]`,
+		},
+		{
+			name: "path parameters", path: "/api/items/{id}/files/{path}", op: "get", key: "parameters",
+			want: `[{"in":"path","name":"id","required":true,"schema":{"type":"string"}},{"in":"path","name":"path","required":true,"schema":{"type":"string"}}]`,
+		},
+		{name: "deprecated", path: "/api/items/{id}/files/{path}", op: "get", key: "deprecated", want: `true`},
+		{
+			name: "request body", path: "/api/items", op: "post", key: "requestBody",
+			want: `{"content":{"application/json":{"schema":{"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"}}},"required":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(doc.Paths[tt.path][tt.op][tt.key])
+			if string(b) != tt.want {
+				t.Errorf("%s %s %s = %s, want %s", tt.op, tt.path, tt.key, b, tt.want)
+			}
+		})
+	}
+
+	responses, _ := doc.Paths["/api/items"]["post"]["responses"].(map[string]any)
+	if _, ok := responses["201"]; !ok {
+		t.Errorf("post /api/items responses = %v, want 201", responses)
+	}
+	if _, ok := responses["default"]; !ok {
+		t.Errorf("post /api/items responses = %v, want default problem", responses)
+	}
+}
@@ -0,0 +1,214 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	ContentTypeProblem = "application/problem+json"
+
+	// StatusKey is the oops context key holding the HTTP status of an error, e.g. oops.With(httpd.StatusKey, http.StatusNotFound).
+	StatusKey = "httpStatus"
+)
+
+// Problem is an RFC 7807 problem details response.
+// Extensions are serialized as additional members of the problem object.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       any
+	RequestID  string
+	Extensions map[string]any
+}
+
+// NewProblem returns a problem for the status with the default status text as title.
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// ProblemFromError converts an error to a problem. The status is taken from the StatusKey in the oops context, or derived from well-known
+// errors, and defaults to 500. Only the public message and code of an oops error are exposed, never the error message itself.
+func ProblemFromError(err error) Problem {
+	status := http.StatusInternalServerError
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+
+	oopsErr, ok := oops.AsOops(err)
+	if !ok {
+		return NewProblem(status, "")
+	}
+
+	if s, ok := oopsErr.Context()[StatusKey].(int); ok && s >= 400 && s <= 599 {
+		status = s
+	}
+
+	p := NewProblem(status, oopsErr.Public())
+	p.Code = oopsErr.Code()
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		p.Extensions = map[string]any{"issues": validationErr.Issues}
+	}
+	return p
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if p.Code != nil && p.Code != "" {
+		m["code"] = p.Code
+	}
+	if p.RequestID != "" {
+		m["requestId"] = p.RequestID
+	}
+	return json.Marshal(m)
+}
+
+// RecoverOption configures the Recover middleware.
+type RecoverOption func(r *recoverer)
+
+// Recover recovers panics in the handler, logs them with their stack trace to the default flow of the slogd LogSet in the request context
+// and responds with a 500 problem. If the handler already started writing the response, Recover panics with http.ErrAbortHandler instead,
+// so net/http aborts the response rather than ending a truncated one normally.
+// Panics with http.ErrAbortHandler are passed on without logging.
+func Recover(opts ...RecoverOption) Middleware {
+	rc := &recoverer{}
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				ctx := r.Context()
+				err := oops.FromContext(ctx).
+					In("httpd").
+					With("method", r.Method).
+					With("path", r.URL.Path).
+					Recoverf(func() { panic(rec) }, "panic serving http request")
+
+				attrs := []slog.Attr{slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err)}
+				if oopsErr, ok := oops.AsOops(err); ok {
+					attrs = append(attrs, slog.String("stacktrace", oopsErr.Stacktrace()))
+				}
+				flowLogger(ctx, rc.flow).LogAttrs(ctx, slogd.LevelError, "panic serving http request", attrs...)
+
+				if rw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+
+				// Drop the headers describing the body the handler meant to write
+				for _, h := range []string{"Content-Length", "Content-Encoding", "ETag", "Last-Modified", "Content-Type"} {
+					rw.Header().Del(h)
+				}
+				WriteProblem(rw, r, ProblemFromError(err))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+type recoverer struct {
+	flow string
+}
+
+// WithRecoverFlow logs recovered panics to the named slogd flow.
+func WithRecoverFlow(name string) RecoverOption {
+	return func(r *recoverer) {
+		r.flow = name
+	}
+}
+
+// WriteError writes the error as a problem response and logs it to the default flow of the slogd LogSet in the request context,
+// at error level for server errors and debug level for client errors.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFromError(err)
+	logError(r, p.Status, err)
+	WriteProblem(w, r, p)
+}
+
+func logError(r *http.Request, status int, err error) {
+	level := slogd.LevelDebug
+	if status >= http.StatusInternalServerError {
+		level = slogd.LevelError
+	}
+
+	ctx := r.Context()
+	slogd.FromContext(ctx).DefaultLogger().LogAttrs(ctx, level, "http request failed",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Any("error", err))
+}
+
+// WriteProblem writes the problem as an application/problem+json response.
+// The instance defaults to the request path, and the request ID is added if the RequestID middleware assigned one.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	if p.RequestID == "" {
+		p.RequestID, _ = RequestIDFromContext(r.Context())
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
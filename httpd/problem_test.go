@@ -0,0 +1,147 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samber/oops"
+)
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+		wantCode   any
+	}{
+		{name: "plain error", err: errors.New("secret"), wantStatus: http.StatusInternalServerError},
+		{name: "oops without status", err: oops.Code("failed").New("secret"), wantStatus: http.StatusInternalServerError, wantCode: "failed"},
+		{name: "oops with status", err: oops.With(StatusKey, http.StatusNotFound).Code("not_found").Public("item not found").New("secret"), wantStatus: http.StatusNotFound, wantDetail: "item not found", wantCode: "not_found"},
+		{name: "wrapped oops with status", err: oops.Wrap(oops.With(StatusKey, http.StatusConflict).New("secret")), wantStatus: http.StatusConflict},
+		{name: "invalid status", err: oops.With(StatusKey, 200).New("secret"), wantStatus: http.StatusInternalServerError},
+		{name: "max bytes", err: &http.MaxBytesError{Limit: 10}, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "deadline", err: context.DeadlineExceeded, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ProblemFromError(tt.err)
+			if p.Status != tt.wantStatus || p.Detail != tt.wantDetail || p.Code != tt.wantCode {
+				t.Errorf("ProblemFromError() = %+v, want status %d, detail %q, code %v", p, tt.wantStatus, tt.wantDetail, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantLog    bool
+		wantAbort  bool
+	}{
+		{
+			name:       "no panic",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "panic",
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			wantStatus: http.StatusInternalServerError,
+			wantLog:    true,
+		},
+		{
+			name: "panic after setting headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Length", "5")
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantLog:    true,
+		},
+		{
+			name: "panic after writing header",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			wantStatus: http.StatusAccepted,
+			wantLog:    true,
+			wantAbort:  true,
+		},
+		{
+			name: "panic after writing body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("partial"))
+				panic("boom")
+			},
+			wantStatus: http.StatusOK,
+			wantLog:    true,
+			wantAbort:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestFlow("recover-" + tt.name)
+			h := Recover(WithRecoverFlow("recover-" + tt.name))(tt.handler)
+
+			w := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if rec := recover(); (rec == http.ErrAbortHandler) != tt.wantAbort {
+						t.Errorf("Recover() panic = %v, wantAbort %v", rec, tt.wantAbort)
+					}
+				}()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+			}()
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Recover() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			records := decodeRecords(t, buf)
+			if tt.wantLog && (len(records) != 1 || records[0]["stacktrace"] == "") {
+				t.Errorf("Recover() log records = %v, want panic with stacktrace", records)
+			}
+			if !tt.wantLog && len(records) != 0 {
+				t.Errorf("Recover() log records = %v, want none", records)
+			}
+
+			if tt.wantStatus == http.StatusInternalServerError {
+				var p map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Header().Get("Content-Type") != ContentTypeProblem {
+					t.Fatalf("Recover() body = %q, error = %v", w.Body.String(), err)
+				}
+				if p["status"] != float64(http.StatusInternalServerError) || p["instance"] != "/panic" {
+					t.Errorf("Recover() problem = %v", p)
+				}
+				for _, h := range []string{"Content-Length", "Content-Encoding", "ETag", "Last-Modified"} {
+					if v := w.Header().Get(h); v != "" {
+						t.Errorf("Recover() header %s = %q, want removed", h, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRecover_abortHandler(t *testing.T) {
+	h := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("Recover() panic = %v, want %v", rec, http.ErrAbortHandler)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,286 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	ProxyRoundRobin ProxyBalancer = iota
+	ProxyLeastConnections
+
+	DefaultProxyEjectionDuration   = 30 * time.Second
+	DefaultProxyEjectionFailures   = 5
+	DefaultProxyHealthCheckTimeout = 2 * time.Second
+)
+
+// ProxyBalancer selects the upstream a request is sent to.
+type ProxyBalancer int
+
+var (
+	errNoUpstream    = errors.New("no healthy upstream")
+	errProxyShutdown = errors.New("proxy shut down before the request completed")
+)
+
+// ProxyOption configures a Proxy.
+type ProxyOption func(p *Proxy)
+
+// NewProxy creates a reverse proxy balancing requests over the upstreams, e.g. http://127.0.0.1:8081.
+// The path of an upstream URL is prefixed to the path of the request.
+func NewProxy(upstreams []string, opts ...ProxyOption) (*Proxy, error) {
+	oopsErr := oops.In("httpd")
+	if len(upstreams) == 0 {
+		return nil, oopsErr.New("no upstreams configured")
+	}
+
+	p := &Proxy{
+		ejectionFailures:   DefaultProxyEjectionFailures,
+		ejectionDuration:   DefaultProxyEjectionDuration,
+		healthCheckTimeout: DefaultProxyHealthCheckTimeout,
+		requestHeaders:     make(map[string]string),
+		responseHeaders:    make(map[string]string),
+		transport:          http.DefaultTransport.(*http.Transport).Clone(),
+	}
+
+	for _, upstream := range upstreams {
+		u, err := url.Parse(upstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, oopsErr.With("upstream", upstream).New("invalid upstream url")
+		}
+		p.upstreams = append(p.upstreams, newProxyUpstream(u))
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.stopCtx, p.stop = context.WithCancel(context.Background())
+	p.forceCtx, p.force = context.WithCancel(context.Background())
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &proxyTransport{proxy: p},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+		ErrorLog:       slog.NewLogLogger(slogd.All().DefaultLogger().Handler(), slogd.LevelError),
+	}
+
+	if p.healthCheckInterval > 0 {
+		go p.runHealthChecks()
+	}
+	return p, nil
+}
+
+// Proxy is a reverse proxy balancing requests over multiple upstreams.
+//
+// Upstreams are taken out of rotation when their active health check fails, or when consecutive requests to them fail, which ejects them
+// for a while. Requests with an idempotent method and without a body are retried on another upstream if the upstream could not be reached
+// or answered with 502, 503 or 504. Every proxied request is logged with the upstream it was sent to.
+// Register Shutdown using WithShutdownHook, so health checks are stopped and proxied requests are completed when the server shuts down.
+type Proxy struct {
+	proxy               *httputil.ReverseProxy
+	upstreams           []*proxyUpstream
+	balancer            ProxyBalancer
+	transport           http.RoundTripper
+	retries             int
+	ejectionFailures    int
+	ejectionDuration    time.Duration
+	healthCheckPath     string
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	requestHeaders      map[string]string
+	responseHeaders     map[string]string
+	flow                string
+
+	mux      sync.Mutex
+	next     int
+	closed   bool
+	inFlight sync.WaitGroup
+	stopCtx  context.Context
+	stop     context.CancelFunc
+	forceCtx context.Context
+	force    context.CancelFunc
+}
+
+// WithProxyBalancer sets how upstreams are selected, which defaults to ProxyRoundRobin.
+func WithProxyBalancer(b ProxyBalancer) ProxyOption {
+	return func(p *Proxy) {
+		p.balancer = b
+	}
+}
+
+// WithProxyEjection ejects an upstream for the duration after the number of consecutive failed requests. Zero failures disables ejection.
+func WithProxyEjection(failures int, d time.Duration) ProxyOption {
+	return func(p *Proxy) {
+		p.ejectionFailures = failures
+		p.ejectionDuration = d
+	}
+}
+
+// WithProxyFlow logs the proxied requests and upstream health changes to the named slogd flow.
+func WithProxyFlow(name string) ProxyOption {
+	return func(p *Proxy) {
+		p.flow = name
+	}
+}
+
+// WithProxyHealthCheck requests the path on every upstream at the interval. Upstreams not answering with a 2xx or 3xx status within
+// the timeout are taken out of rotation until they pass the health check again. A zero timeout uses DefaultProxyHealthCheckTimeout.
+func WithProxyHealthCheck(path string, interval, timeout time.Duration) ProxyOption {
+	return func(p *Proxy) {
+		if timeout <= 0 {
+			timeout = DefaultProxyHealthCheckTimeout
+		}
+
+		p.healthCheckPath = path
+		p.healthCheckInterval = interval
+		p.healthCheckTimeout = timeout
+	}
+}
+
+// WithProxyRequestHeader sets a header on the requests sent to the upstreams. An empty value removes the header.
+func WithProxyRequestHeader(name, value string) ProxyOption {
+	return func(p *Proxy) {
+		p.requestHeaders[name] = value
+	}
+}
+
+// WithProxyResponseHeader sets a header on the responses of the upstreams. An empty value removes the header.
+func WithProxyResponseHeader(name, value string) ProxyOption {
+	return func(p *Proxy) {
+		p.responseHeaders[name] = value
+	}
+}
+
+// WithProxyRetries sets the number of times a failed idempotent request is retried on another upstream.
+func WithProxyRetries(n int) ProxyOption {
+	return func(p *Proxy) {
+		p.retries = n
+	}
+}
+
+// WithProxyTransport sets the transport used to send requests to the upstreams.
+func WithProxyTransport(t http.RoundTripper) ProxyOption {
+	return func(p *Proxy) {
+		p.transport = t
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		WriteProblem(w, r, NewProblem(http.StatusServiceUnavailable, "server is shutting down"))
+		return
+	}
+	p.inFlight.Add(1)
+	p.mux.Unlock()
+	defer p.inFlight.Done()
+
+	// Requests still in flight when the shutdown timeout expires are cancelled, including upgraded connections
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+	defer context.AfterFunc(p.forceCtx, func() { cancel(errProxyShutdown) })()
+
+	start := time.Now()
+	attempt := &proxyAttempt{}
+	rw := newResponseWriter(w)
+	p.proxy.ServeHTTP(rw, r.WithContext(context.WithValue(ctx, proxyCtxKey, attempt)))
+
+	level := slogd.LevelInfo
+	if rw.Status() >= http.StatusInternalServerError {
+		level = slogd.LevelWarn
+	}
+
+	log := flowLogger(ctx, p.flow)
+	log.LogAttrs(ctx, level, "proxied request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("upstream", attempt.upstream),
+		slog.Int("attempts", attempt.count),
+		slog.Int("status", rw.Status()),
+		slog.Int64("bytes", rw.bytes),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("remoteAddress", r.RemoteAddr))
+}
+
+// Shutdown stops the health checks and rejects new requests, and waits until the requests in flight have completed or the context expires,
+// after which they are cancelled.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mux.Lock()
+	p.closed = true
+	p.mux.Unlock()
+	p.stop()
+
+	done := make(chan struct{})
+	go func() {
+		p.inFlight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.force()
+		err = ctx.Err()
+	}
+
+	if t, ok := p.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	return err
+}
+
+func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadGateway
+	switch {
+	case errors.Is(err, errNoUpstream), errors.Is(context.Cause(r.Context()), errProxyShutdown):
+		status = http.StatusServiceUnavailable
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client went away, there is no one to answer
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	WriteError(w, r, oops.FromContext(r.Context()).In("httpd").With(StatusKey, status).Public(http.StatusText(status)).Wrap(err))
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	for name, value := range p.responseHeaders {
+		if value == "" {
+			resp.Header.Del(name)
+			continue
+		}
+		resp.Header.Set(name, value)
+	}
+	return nil
+}
+
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetXForwarded()
+
+	for name, value := range p.requestHeaders {
+		if value == "" {
+			pr.Out.Header.Del(name)
+			continue
+		}
+		pr.Out.Header.Set(name, value)
+	}
+}
+
+// proxyAttempt records where a request was sent, for logging.
+type proxyAttempt struct {
+	upstream string
+	count    int
+}
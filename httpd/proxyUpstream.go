@@ -0,0 +1,304 @@
+package httpd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+func newProxyUpstream(u *url.URL) *proxyUpstream {
+	upstream := &proxyUpstream{url: u}
+	upstream.healthy.Store(true)
+	return upstream
+}
+
+// proxyUpstream keeps track of the health and load of an upstream.
+type proxyUpstream struct {
+	url     *url.URL
+	healthy atomic.Bool
+	active  atomic.Int64
+
+	mux          sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// available reports if the upstream passed its last health check and is not ejected.
+func (u *proxyUpstream) available(now time.Time) bool {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	return u.healthy.Load() && !now.Before(u.ejectedUntil)
+}
+
+// record counts consecutive failures, and reports if the upstream was ejected by this failure.
+func (u *proxyUpstream) record(failed bool, threshold int, d time.Duration) bool {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if !failed {
+		u.failures = 0
+		return false
+	}
+
+	u.failures++
+	if threshold <= 0 || u.failures < threshold {
+		return false
+	}
+
+	u.failures = 0
+	u.ejectedUntil = time.Now().Add(d)
+	return true
+}
+
+// selectUpstream returns an available upstream that was not tried yet for the request.
+func (p *Proxy) selectUpstream(tried []*proxyUpstream) (*proxyUpstream, bool) {
+	now := time.Now()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	var selected *proxyUpstream
+	for i := range p.upstreams {
+		// Start after the previously selected upstream for round-robin
+		u := p.upstreams[(p.next+i)%len(p.upstreams)]
+		if slices.Contains(tried, u) || !u.available(now) {
+			continue
+		}
+
+		if p.balancer == ProxyRoundRobin {
+			selected = u
+			break
+		}
+
+		if selected == nil || u.active.Load() < selected.active.Load() {
+			selected = u
+		}
+	}
+
+	if selected == nil {
+		return nil, false
+	}
+	p.next = (slices.Index(p.upstreams, selected) + 1) % len(p.upstreams)
+	return selected, true
+}
+
+func (p *Proxy) runHealthChecks() {
+	ticker := time.NewTicker(p.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.checkHealth(p.stopCtx)
+
+		select {
+		case <-p.stopCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth requests the health check path on all upstreams concurrently.
+func (p *Proxy) checkHealth(ctx context.Context) {
+	log := flowLogger(ctx, p.flow)
+
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := p.probe(ctx, u)
+			if ctx.Err() != nil {
+				return
+			}
+
+			healthy := err == nil
+			if u.healthy.Swap(healthy) == healthy {
+				return
+			}
+
+			if healthy {
+				log.LogAttrs(ctx, slogd.LevelInfo, "upstream healthy", slog.String("upstream", u.url.String()))
+				return
+			}
+			log.LogAttrs(ctx, slogd.LevelWarn, "upstream unhealthy", slog.String("upstream", u.url.String()), slog.Any("error", err))
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Proxy) probe(ctx context.Context, u *proxyUpstream) error {
+	ctx, cancel := context.WithTimeout(ctx, p.healthCheckTimeout)
+	defer cancel()
+
+	target := u.url.JoinPath(p.healthCheckPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return oops.In("httpd").With("upstream", u.url.String()).With("status", resp.StatusCode).New("health check failed")
+	}
+	return nil
+}
+
+// proxyTransport sends a request to an upstream selected by the balancer, retrying idempotent requests on another upstream.
+type proxyTransport struct {
+	proxy *Proxy
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.proxy
+	log := flowLogger(req.Context(), p.flow)
+	attempt, _ := req.Context().Value(proxyCtxKey).(*proxyAttempt)
+
+	retries := 0
+	if retryable(req) {
+		retries = p.retries
+	}
+
+	u, ok := p.selectUpstream(nil)
+	if !ok {
+		return nil, errNoUpstream
+	}
+
+	var tried []*proxyUpstream
+	for {
+		tried = append(tried, u)
+
+		if attempt != nil {
+			attempt.upstream = u.url.String()
+			attempt.count++
+		}
+
+		out := req.Clone(req.Context())
+		if req.GetBody != nil && len(tried) > 1 {
+			out.Body, _ = req.GetBody()
+		}
+		out.URL.Scheme = u.url.Scheme
+		out.URL.Host = u.url.Host
+		out.URL.Path, out.URL.RawPath = joinUrlPath(u.url, req.URL)
+		out.Host = ""
+
+		u.active.Add(1)
+		resp, err := p.transport.RoundTrip(out)
+
+		failed := err != nil || resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable ||
+			resp.StatusCode == http.StatusGatewayTimeout
+		if err != nil && req.Context().Err() != nil {
+			// The client went away, which says nothing about the upstream
+			failed = false
+		}
+
+		if u.record(failed, p.ejectionFailures, p.ejectionDuration) {
+			log.LogAttrs(req.Context(), slogd.LevelWarn, "upstream ejected",
+				slog.String("upstream", u.url.String()),
+				slog.Duration("duration", p.ejectionDuration))
+		}
+
+		var next *proxyUpstream
+		if failed && len(tried) <= retries && req.Context().Err() == nil {
+			next, _ = p.selectUpstream(tried)
+		}
+
+		if next == nil {
+			// Without another upstream to retry on, the last failure is passed on to the client
+			if err != nil {
+				u.active.Add(-1)
+				return nil, err
+			}
+			return trackActive(resp, u), nil
+		}
+
+		// Retry on another upstream
+		if err == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		}
+		u.active.Add(-1)
+		u = next
+	}
+}
+
+// retryable reports if the request can safely be sent again: idempotent and without a body, or with a body that can be recreated.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// trackActive keeps the upstream counted as active until the response body is closed, for the least-connections balancer.
+func trackActive(resp *http.Response, u *proxyUpstream) *http.Response {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The body of an upgraded connection must remain an io.ReadWriteCloser, so it is not tracked
+		u.active.Add(-1)
+		return resp
+	}
+
+	resp.Body = &activeBody{ReadCloser: resp.Body, upstream: u}
+	return resp
+}
+
+type activeBody struct {
+	io.ReadCloser
+	upstream *proxyUpstream
+	once     sync.Once
+}
+
+func (b *activeBody) Close() error {
+	b.once.Do(func() {
+		b.upstream.active.Add(-1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// joinUrlPath joins the paths of the upstream and request URLs, keeping the escaped form of the path, e.g. /a%2Fb.
+func joinUrlPath(a, b *url.URL) (string, string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+
+	aPath, bPath := a.EscapedPath(), b.EscapedPath()
+	switch aSlash, bSlash := strings.HasSuffix(aPath, "/"), strings.HasPrefix(bPath, "/"); {
+	case aSlash && bSlash:
+		return a.Path + b.Path[1:], aPath + bPath[1:]
+	case !aSlash && !bSlash:
+		return a.Path + "/" + b.Path, aPath + "/" + bPath
+	default:
+		return a.Path + b.Path, aPath + bPath
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch aSlash, bSlash := strings.HasSuffix(a, "/"), strings.HasPrefix(b, "/"); {
+	case aSlash && bSlash:
+		return a + b[1:]
+	case !aSlash && !bSlash:
+		return a + "/" + b
+	default:
+		return a + b
+	}
+}
@@ -0,0 +1,289 @@
+package httpd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newUpstream(t *testing.T, name string, status int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Internal", "secret")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, name+":"+r.URL.Path+":"+r.Header.Get("X-Gateway"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func proxyGet(t *testing.T, h http.Handler, method, path string) (int, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestProxy(t *testing.T) {
+	newTestFlow("proxy")
+	a := newUpstream(t, "a", http.StatusOK)
+	b := newUpstream(t, "b", http.StatusOK)
+
+	p, err := NewProxy([]string{a.URL + "/api", b.URL},
+		WithProxyFlow("proxy"),
+		WithProxyRequestHeader("X-Gateway", "yes"),
+		WithProxyResponseHeader("X-Internal", ""))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+
+	want := []string{"a:/api/users:yes", "b:/users:yes", "a:/api/users:yes"}
+	for _, w := range want {
+		if status, body := proxyGet(t, p, http.MethodGet, "/users"); status != http.StatusOK || body != w {
+			t.Errorf("ServeHTTP() = %d %q, want %q", status, body, w)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("X-Internal") != "" || rec.Header().Get("X-Upstream") == "" {
+		t.Errorf("ServeHTTP() response headers not rewritten: %v", rec.Header())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = p.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if status, _ := proxyGet(t, p, http.MethodGet, "/"); status != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() after Shutdown() status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+}
+
+func TestProxy_cancelled(t *testing.T) {
+	newTestFlow("proxy")
+
+	tests := []struct {
+		name       string
+		cancel     func(p *Proxy, cancelClient context.CancelFunc)
+		wantStatus int
+	}{
+		{
+			name: "shutdown",
+			cancel: func(p *Proxy, _ context.CancelFunc) {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				_ = p.Shutdown(ctx)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "client gone",
+			cancel: func(_ *Proxy, cancelClient context.CancelFunc) { cancelClient() },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan struct{})
+			release := make(chan struct{})
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(received)
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer upstream.Close()
+			defer close(release)
+
+			p, err := NewProxy([]string{upstream.URL}, WithProxyFlow("proxy"))
+			if err != nil {
+				t.Fatalf("NewProxy() error = %v", err)
+			}
+			defer p.Shutdown(context.Background())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			w := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.ServeHTTP(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil))
+			}()
+
+			<-received
+			tt.cancel(p, cancel)
+			<-done
+
+			if tt.wantStatus == 0 {
+				if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+					t.Errorf("ServeHTTP() wrote %d %q, want nothing", w.Code, w.Body.String())
+				}
+				return
+			}
+			if w.Code != tt.wantStatus || w.Header().Get("Content-Type") != ContentTypeProblem {
+				t.Errorf("ServeHTTP() = %d %q, want %d problem", w.Code, w.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProxy_failures(t *testing.T) {
+	newTestFlow("proxy")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	failing := newUpstream(t, "failing", http.StatusServiceUnavailable)
+	up := newUpstream(t, "up", http.StatusOK)
+
+	tests := []struct {
+		name       string
+		upstreams  []string
+		opts       []ProxyOption
+		method     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "retry unreachable", upstreams: []string{down.URL, up.URL}, opts: []ProxyOption{WithProxyRetries(1)}, method: http.MethodGet, wantStatus: http.StatusOK, wantBody: "up:/:"},
+		{name: "retry unavailable", upstreams: []string{failing.URL, up.URL}, opts: []ProxyOption{WithProxyRetries(1)}, method: http.MethodGet, wantStatus: http.StatusOK, wantBody: "up:/:"},
+		{name: "no retry for post", upstreams: []string{down.URL, up.URL}, opts: []ProxyOption{WithProxyRetries(1)}, method: http.MethodPost, wantStatus: http.StatusBadGateway},
+		{name: "no retries", upstreams: []string{failing.URL, up.URL}, method: http.MethodGet, wantStatus: http.StatusServiceUnavailable, wantBody: "failing:/:"},
+		{name: "all unreachable", upstreams: []string{down.URL}, opts: []ProxyOption{WithProxyRetries(3)}, method: http.MethodGet, wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProxy(tt.upstreams, append(tt.opts, WithProxyFlow("proxy"))...)
+			if err != nil {
+				t.Fatalf("NewProxy() error = %v", err)
+			}
+
+			status, body := proxyGet(t, p, tt.method, "/")
+			if status != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t.Errorf("ServeHTTP() body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxy_ejection(t *testing.T) {
+	newTestFlow("proxy")
+	failing := newUpstream(t, "failing", http.StatusBadGateway)
+	up := newUpstream(t, "up", http.StatusOK)
+
+	p, err := NewProxy([]string{failing.URL, up.URL}, WithProxyFlow("proxy"), WithProxyEjection(1, time.Minute))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+
+	if status, _ := proxyGet(t, p, http.MethodGet, "/"); status != http.StatusBadGateway {
+		t.Fatalf("ServeHTTP() status = %d, want %d", status, http.StatusBadGateway)
+	}
+
+	// The failing upstream is ejected, so all requests go to the other upstream
+	for range 3 {
+		if _, body := proxyGet(t, p, http.MethodGet, "/"); body != "up:/:" {
+			t.Errorf("ServeHTTP() body = %q, want the healthy upstream", body)
+		}
+	}
+}
+
+func TestProxy_healthCheck(t *testing.T) {
+	newTestFlow("proxy")
+	unhealthy := newUpstream(t, "unhealthy", http.StatusInternalServerError)
+
+	p, err := NewProxy([]string{unhealthy.URL}, WithProxyFlow("proxy"), WithProxyHealthCheck("/health", time.Hour, time.Second))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+	defer p.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, _ := proxyGet(t, p, http.MethodGet, "/")
+		if status == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ServeHTTP() status = %d, want %d after failed health check", status, http.StatusServiceUnavailable)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxy_leastConnections(t *testing.T) {
+	p, err := NewProxy([]string{"http://a", "http://b", "http://c"}, WithProxyBalancer(ProxyLeastConnections))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+	p.upstreams[0].active.Store(2)
+	p.upstreams[1].active.Store(1)
+	p.upstreams[2].active.Store(3)
+
+	if u, _ := p.selectUpstream(nil); u != p.upstreams[1] {
+		t.Errorf("selectUpstream() = %s, want http://b", u.url)
+	}
+	if u, _ := p.selectUpstream([]*proxyUpstream{p.upstreams[1]}); u != p.upstreams[0] {
+		t.Errorf("selectUpstream() = %s, want http://a", u.url)
+	}
+}
+
+func TestWithProxyHealthCheck(t *testing.T) {
+	p := &Proxy{}
+	WithProxyHealthCheck("/health", time.Minute, 0)(p)
+	if p.healthCheckTimeout != DefaultProxyHealthCheckTimeout {
+		t.Errorf("WithProxyHealthCheck() timeout = %v, want %v", p.healthCheckTimeout, DefaultProxyHealthCheckTimeout)
+	}
+}
+
+func Test_joinUrlPath(t *testing.T) {
+	tests := []struct {
+		upstream    string
+		request     string
+		wantPath    string
+		wantRawPath string
+	}{
+		{upstream: "http://a", request: "/users", wantPath: "/users"},
+		{upstream: "http://a/api/", request: "/users", wantPath: "/api/users"},
+		{upstream: "http://a/api", request: "users", wantPath: "/api/users"},
+		{upstream: "http://a/api", request: "/files/a%2Fb", wantPath: "/api/files/a/b", wantRawPath: "/api/files/a%2Fb"},
+		{upstream: "http://a/api/", request: "/a%2Fb", wantPath: "/api/a/b", wantRawPath: "/api/a%2Fb"},
+		{upstream: "http://a/a%2Fb", request: "/users", wantPath: "/a/b/users", wantRawPath: "/a%2Fb/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream+" "+tt.request, func(t *testing.T) {
+			a, _ := url.Parse(tt.upstream)
+			b, _ := url.Parse(tt.request)
+			if path, rawPath := joinUrlPath(a, b); path != tt.wantPath || rawPath != tt.wantRawPath {
+				t.Errorf("joinUrlPath() = %q, %q, want %q, %q", path, rawPath, tt.wantPath, tt.wantRawPath)
+			}
+		})
+	}
+}
+
+func TestProxy_escapedPath(t *testing.T) {
+	newTestFlow("proxy")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.EscapedPath())
+	}))
+	defer srv.Close()
+
+	p, err := NewProxy([]string{srv.URL + "/api"}, WithProxyFlow("proxy"))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+	defer p.Shutdown(context.Background())
+
+	if _, body := proxyGet(t, p, http.MethodGet, "/files/a%2Fb"); body != "/api/files/a%2Fb" {
+		t.Errorf("ServeHTTP() upstream path = %q, want /api/files/a%%2Fb", body)
+	}
+}
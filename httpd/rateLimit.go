@@ -0,0 +1,97 @@
+package httpd
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimit limits requests per key using a token bucket, which refills at rate tokens per second up to burst tokens.
+// Requests exceeding the limit are rejected with 429 Too Many Requests and a Retry-After header.
+func RateLimit(rate float64, burst int, opts ...LimitOption) Middleware {
+	l := newLimitConfig(opts)
+	b := newTokenBuckets(rate, burst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := l.key(r)
+			allowed, retryAfter := b.take(key, time.Now())
+			if !allowed {
+				l.log(r, "rate limit exceeded", false, key, slog.Duration("retryAfter", retryAfter))
+				l.reject(w, r, http.StatusTooManyRequests, retryAfter, "rate limit exceeded")
+				return
+			}
+
+			l.log(r, "rate limit allowed", true, key)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTokenBuckets(rate float64, burst int) *tokenBuckets {
+	return &tokenBuckets{
+		rate:    rate,
+		burst:   math.Max(1, float64(burst)),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// tokenBuckets holds a token bucket per key. Buckets that have refilled completely are removed periodically.
+type tokenBuckets struct {
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	mux       sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token from the bucket of the key. If no token is available, it returns how long to wait for the next token.
+func (b *tokenBuckets) take(key string, now time.Time) (bool, time.Duration) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.sweep(now)
+
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: b.burst, last: now}
+		b.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(b.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*b.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	if b.rate <= 0 {
+		return false, rateLimitSweepInterval
+	}
+	return false, time.Duration((1 - bucket.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBuckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, bucket := range b.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*b.rate >= b.burst {
+			delete(b.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package httpd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID assigns a request ID to every request. The ID is taken from the X-Request-ID header, or the trace ID of the
+// W3C traceparent header, and a random ID is generated if neither is present or valid.
+// The ID is stored in the request context, echoed in the X-Request-ID response header and added as requestId to every record
+// logged by slogd with the request context. The oops builder in the context carries the ID as trace, so errors created using
+// oops.FromContext can be correlated with the request.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := requestIDFromHeaders(r.Header)
+			w.Header().Set(HeaderRequestID, id)
+
+			ctx := context.WithValue(r.Context(), requestIDCtxKey, id)
+			ctx = slogd.WithAttrs(ctx, slog.String("requestId", id))
+			ctx = oops.WithBuilder(ctx, oops.FromContext(ctx).Trace(id).With("requestId", id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID assigned by the RequestID middleware.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDCtxKey).(string)
+	return id, ok
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func requestIDFromHeaders(h http.Header) string {
+	if id := h.Get(HeaderRequestID); validRequestID(id) {
+		return id
+	}
+
+	if id, ok := traceIDFromTraceparent(h.Get(HeaderTraceparent)); ok {
+		return id
+	}
+	return newRequestID()
+}
+
+// traceIDFromTraceparent returns the trace ID of a W3C traceparent header: version-traceid-parentid-flags.
+func traceIDFromTraceparent(traceparent string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+
+	// Version 00 has exactly four fields, future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", false
+	}
+
+	for _, part := range parts[:4] {
+		if !isLowerHex(part) {
+			return "", false
+		}
+	}
+
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRequestID only accepts IDs of printable ASCII characters, so they are safe to echo and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
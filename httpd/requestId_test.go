@@ -0,0 +1,65 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "request id", headers: map[string]string{HeaderRequestID: "abc-123"}, want: "abc-123"},
+		{name: "traceparent", headers: map[string]string{HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "request id before traceparent", headers: map[string]string{HeaderRequestID: "abc-123", HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, want: "abc-123"},
+		{name: "invalid request id", headers: map[string]string{HeaderRequestID: "abc 123"}},
+		{name: "invalid traceparent", headers: map[string]string{HeaderTraceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}},
+		{name: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestFlow("request-id-" + tt.name)
+
+			var ctxID string
+			var err error
+			h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID, _ = RequestIDFromContext(r.Context())
+				slogd.FromContext(r.Context()).Logger("request-id-"+tt.name).InfoContext(r.Context(), "handled")
+				err = oops.FromContext(r.Context()).New("failed")
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(HeaderRequestID)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("RequestID() = %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && len(got) != 32 {
+				t.Errorf("RequestID() generated %q, want 32 hex characters", got)
+			}
+			if ctxID != got {
+				t.Errorf("RequestIDFromContext() = %q, want %q", ctxID, got)
+			}
+
+			if records := decodeRecords(t, buf); len(records) != 1 || records[0]["requestId"] != got {
+				t.Errorf("RequestID() log records = %v, want requestId %q", records, got)
+			}
+
+			if oopsErr, ok := oops.AsOops(err); !ok || oopsErr.Trace() != got {
+				t.Errorf("RequestID() oops trace = %v, want %q", err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	envRestartParentPid = "HTTPD_RESTART_PPID"
+	envRestartReadyFd   = "HTTPD_RESTART_READY_FD"
+)
+
+var (
+	served          = make(map[net.Listener]string)
+	servedMux       sync.Mutex
+	registeredFiles = make(map[string]*os.File)
+	restartNotifier func(pid int) error
+	restart         = newRestartState()
+	restartMux      sync.Mutex
+)
+
+func newRestartState() *restartState {
+	return &restartState{
+		drain: make(chan struct{}),
+	}
+}
+
+// restartState signals the running servers to drain once a new process has taken over the listeners.
+type restartState struct {
+	drain chan struct{}
+	once  sync.Once
+}
+
+func (r *restartState) draining() <-chan struct{} {
+	return r.drain
+}
+
+func (r *restartState) startDraining() {
+	r.once.Do(func() {
+		close(r.drain)
+	})
+}
+
+func currentRestartState() *restartState {
+	restartMux.Lock()
+	defer restartMux.Unlock()
+
+	return restart
+}
+
+// NotifyRestartReady tells the parent process that started a graceful restart that the new process is ready to serve.
+// It is called automatically once all inherited listeners have been claimed, and is a no-op if the process was not started by a restart.
+func NotifyRestartReady() error {
+	fd := os.Getenv(envRestartReadyFd)
+	if fd == "" {
+		return nil
+	}
+	_ = os.Unsetenv(envRestartReadyFd)
+
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return oops.In("httpd").With(envRestartReadyFd, fd).New("invalid restart readiness file descriptor")
+	}
+
+	f := os.NewFile(uintptr(n), "restart-ready")
+	defer f.Close()
+
+	if _, err = f.Write([]byte{1}); err != nil {
+		return oops.In("httpd").With(envRestartReadyFd, fd).Wrap(err)
+	}
+	return nil
+}
+
+// RegisterRestartFile passes the file to the new process started by Restart, with its file descriptor in the environment variable,
+// and returns a function to remove it. Use it to hand over resources the new process cannot acquire while this process runs,
+// e.g. the single-instance lock of an application using application.LockFile and application.EnvLockFd.
+func RegisterRestartFile(env string, f *os.File) func() {
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	registeredFiles[env] = f
+	return func() {
+		servedMux.Lock()
+		defer servedMux.Unlock()
+
+		if registeredFiles[env] == f {
+			delete(registeredFiles, env)
+		}
+	}
+}
+
+// RegisterRestartNotifier sets the function called with the pid of the new process started by Restart once it is ready,
+// before the running servers drain, and returns a function to remove it. Use it to make the new process the main process of the
+// service manager, e.g. using application.NotifyMainPid, which systemd requires for services of Type=notify.
+func RegisterRestartNotifier(notify func(pid int) error) func() {
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	restartNotifier = notify
+	return func() {
+		servedMux.Lock()
+		defer servedMux.Unlock()
+
+		restartNotifier = nil
+	}
+}
+
+// Restart starts a new instance of the executable with the same arguments and passes down the listeners of all running servers,
+// and the files registered using RegisterRestartFile.
+// When the new process reports it is ready within readyTimeout, the notifier registered using RegisterRestartNotifier is called
+// and the running servers drain their connections and return.
+// If the new process fails to start, does not report readiness in time or the notifier fails, it is killed and the running servers keep serving.
+func Restart(ctx context.Context, log *slog.Logger, readyTimeout time.Duration) error {
+	oopsErr := oops.FromContext(ctx).In("httpd")
+
+	listeners, names := servedListeners()
+	if len(listeners) == 0 {
+		return oopsErr.New("no listeners to hand over")
+	}
+
+	var err error
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	for _, l := range listeners {
+		var f *os.File
+		if f, err = listenerFile(l); err != nil {
+			return oopsErr.With("listenAddress", l.Addr().String()).Wrap(err)
+		}
+		files = append(files, f)
+	}
+
+	var ready, readyWriter *os.File
+	if ready, readyWriter, err = os.Pipe(); err != nil {
+		return oopsErr.Wrap(err)
+	}
+	defer ready.Close()
+
+	var executable string
+	if executable, err = os.Executable(); err != nil {
+		_ = readyWriter.Close()
+		return oopsErr.Wrap(err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyWriter)
+	cmd.Env = append(restartEnv(os.Environ()),
+		envListenFds+"="+strconv.Itoa(len(files)),
+		envListenFdNames+"="+strings.Join(names, ":"),
+		envRestartParentPid+"="+strconv.Itoa(os.Getpid()),
+		envRestartReadyFd+"="+strconv.Itoa(listenFdsStart+len(files)))
+
+	// Registered files follow the listeners and the readiness pipe
+	for env, f := range restartFiles() {
+		cmd.Env = append(cmd.Env, env+"="+strconv.Itoa(listenFdsStart+len(cmd.ExtraFiles)))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	}
+
+	log.LogAttrs(ctx, slogd.LevelInfo, "starting new process for graceful restart", slog.String("executable", executable), slog.Int("listeners", len(files)))
+	err = cmd.Start()
+	_ = readyWriter.Close()
+	if err != nil {
+		log.LogAttrs(ctx, slogd.LevelError, "failed to start new process", slog.String("error", err.Error()))
+		return oopsErr.Wrap(err)
+	}
+
+	if err = waitForReady(ready, readyTimeout); err != nil {
+		log.LogAttrs(ctx, slogd.LevelError, "new process did not become ready", slog.Int("pid", cmd.Process.Pid), slog.String("error", err.Error()))
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return oopsErr.With("pid", cmd.Process.Pid).Wrap(err)
+	}
+
+	if notify := currentRestartNotifier(); notify != nil {
+		if err = notify(cmd.Process.Pid); err != nil {
+			log.LogAttrs(ctx, slogd.LevelError, "failed to hand over to new process", slog.Int("pid", cmd.Process.Pid), slog.String("error", err.Error()))
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return oopsErr.With("pid", cmd.Process.Pid).Wrap(err)
+		}
+	}
+	log.LogAttrs(ctx, slogd.LevelInfo, "new process is ready, draining connections", slog.Int("pid", cmd.Process.Pid))
+
+	// The new process owns the unix sockets now, make sure closing the listeners does not remove the socket files
+	for _, l := range listeners {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	_ = cmd.Process.Release()
+	currentRestartState().startDraining()
+	return nil
+}
+
+// RunRestartHandler waits for any of the supplied signals and triggers a graceful restart, until the context is cancelled.
+// The signals must not be part of the application shutdown signals.
+func RunRestartHandler(ctx context.Context, log *slog.Logger, readyTimeout time.Duration, signals ...os.Signal) error {
+	if len(signals) == 0 {
+		return oops.FromContext(ctx).In("httpd").New("no restart signals configured")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, signals...)
+	defer signal.Stop(sig)
+
+	log.LogAttrs(ctx, slogd.LevelTrace, "awaiting restart signal", slog.Any("signals", signals))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-currentRestartState().draining():
+			return nil
+		case s := <-sig:
+			log.LogAttrs(ctx, slogd.LevelInfo, "restart signal received", slog.Any("signal", s))
+			if err := Restart(ctx, log, readyTimeout); err != nil {
+				log.LogAttrs(ctx, slogd.LevelWarn, "graceful restart failed", slog.Any("error", err))
+				continue
+			}
+			return nil
+		}
+	}
+}
+
+// registerListener adds the listener to the listeners handed over during a graceful restart and returns a function to remove it.
+// The listener is passed on with the name it was inherited with, or its address, so the new process can match it to its configuration.
+func registerListener(l net.Listener) func() {
+	name := inheritedListenerName(l)
+	if name == "" {
+		name = l.Addr().String()
+	}
+
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	served[l] = name
+	return func() {
+		servedMux.Lock()
+		defer servedMux.Unlock()
+
+		delete(served, l)
+	}
+}
+
+func servedListeners() ([]net.Listener, []string) {
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	listeners := make([]net.Listener, 0, len(served))
+	names := make([]string, 0, len(served))
+	for l, name := range served {
+		listeners = append(listeners, l)
+		names = append(names, listenerNameEscaper.Replace(name))
+	}
+	return listeners, names
+}
+
+func currentRestartNotifier() func(pid int) error {
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	return restartNotifier
+}
+
+func restartFiles() map[string]*os.File {
+	servedMux.Lock()
+	defer servedMux.Unlock()
+
+	files := make(map[string]*os.File, len(registeredFiles))
+	for env, f := range registeredFiles {
+		files[env] = f
+	}
+	return files
+}
+
+func listenerFile(l net.Listener) (*os.File, error) {
+	switch t := l.(type) {
+	case *net.TCPListener:
+		return t.File()
+	case *net.UnixListener:
+		return t.File()
+	default:
+		return nil, errors.New("unsupported listener type")
+	}
+}
+
+// restartEnv removes the socket activation variables of the current process from the environment.
+func restartEnv(env []string) []string {
+	filtered := make([]string, 0, len(env))
+	for _, e := range env {
+		switch strings.SplitN(e, "=", 2)[0] {
+		case envListenPid, envListenFds, envListenFdNames, envRestartParentPid, envRestartReadyFd:
+			continue
+		default:
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// waitForReady blocks until the new process writes to the readiness pipe, closes it or the timeout expires.
+func waitForReady(ready *os.File, timeout time.Duration) error {
+	if err := ready.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1)
+	if _, err := ready.Read(buf); err != nil {
+		return err
+	}
+	return nil
+}
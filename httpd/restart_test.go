@@ -0,0 +1,174 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jantytgat/go-kit/application"
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	envRestartHelper     = "HTTPD_TEST_RESTART_HELPER"
+	envRestartHelperLock = "HTTPD_TEST_RESTART_HELPER_LOCK"
+)
+
+// TestRestartHelperProcess is not a real test, it runs the new process started by TestRestart.
+func TestRestartHelperProcess(t *testing.T) {
+	socketPath := os.Getenv(envRestartHelper)
+	if socketPath == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if lockPath := os.Getenv(envRestartHelperLock); lockPath != "" {
+		if _, err := application.NewFileLocker(lockPath).Lock(ctx); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "child")
+	})
+	_ = RunSocketHttpServer(ctx, slogd.All().DefaultLogger(), socketPath, mux, time.Second)
+	os.Exit(0)
+}
+
+func newUnixClient(socketPath string) *http.Client {
+	return &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+func getBody(client *http.Client) string {
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestRestart(t *testing.T) {
+	log := slogd.All().DefaultLogger()
+	socketPath := filepath.Join(t.TempDir(), "restart.sock")
+	lockPath := filepath.Join(t.TempDir(), "restart.lock")
+
+	// The new process takes over the single-instance lock, which it could not acquire otherwise
+	locker := application.NewFileLocker(lockPath)
+	if _, err := locker.Lock(context.Background()); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	lockFile, _ := application.LockFile(locker)
+	t.Cleanup(RegisterRestartFile(application.EnvLockFd, lockFile))
+
+	// The new process becomes the main process of the service manager
+	notifyPath := filepath.Join(t.TempDir(), "notify.sock")
+	notifySocket, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: notifyPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to create notify socket: %v", err)
+	}
+	t.Cleanup(func() { _ = notifySocket.Close() })
+	t.Setenv("NOTIFY_SOCKET", notifyPath)
+	t.Cleanup(RegisterRestartNotifier(application.NotifyMainPid))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		restartMux.Lock()
+		restart = newRestartState()
+		restartMux.Unlock()
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "parent")
+	})
+	go func() {
+		_ = RunSocketHttpServer(ctx, log, socketPath, mux, time.Second)
+	}()
+
+	client := newUnixClient(socketPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for getBody(client) != "parent" {
+		if time.Now().After(deadline) {
+			t.Fatal("parent server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestRestartHelperProcess$"}
+	defer func() { os.Args = args }()
+	t.Setenv(envRestartHelper, socketPath)
+	t.Setenv(envRestartHelperLock, lockPath)
+
+	if err = Restart(ctx, log, 5*time.Second); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+
+	buf := make([]byte, 256)
+	_ = notifySocket.SetReadDeadline(time.Now().Add(time.Second))
+	n, _ := notifySocket.Read(buf)
+	if got := string(buf[:n]); !strings.HasPrefix(got, "MAINPID=") || !strings.HasSuffix(got, "\nREADY=1") || got == "MAINPID="+strconv.Itoa(os.Getpid())+"\nREADY=1" {
+		t.Errorf("Restart() notified %q, want MAINPID of the new process and READY=1", got)
+	}
+
+	select {
+	case <-currentRestartState().draining():
+	default:
+		t.Error("Restart() did not start draining the running servers")
+	}
+
+	for getBody(client) != "child" {
+		if time.Now().After(deadline) {
+			t.Fatal("new process did not take over the listener")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err = locker.Unlock(context.Background()); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+	if _, err := application.NewFileLocker(lockPath).Lock(context.Background()); !errors.Is(err, application.ErrLocked) {
+		t.Errorf("Lock() while the new process runs error = %v, want %v", err, application.ErrLocked)
+	}
+}
+
+func Test_servedListeners_names(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer l.Close()
+	defer registerListener(l)()
+
+	_, names := servedListeners()
+	if len(names) != 1 || strings.Contains(names[0], ":") {
+		t.Fatalf("servedListeners() names = %v, want one name without separators", names)
+	}
+	if name, _ := url.PathUnescape(names[0]); name != l.Addr().String() {
+		t.Errorf("servedListeners() name = %q, want %q", name, l.Addr().String())
+	}
+}
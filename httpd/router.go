@@ -0,0 +1,239 @@
+package httpd
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Oudwins/zog"
+)
+
+// NewRouter creates a Router on a new http.ServeMux, applying the middleware to all routes.
+func NewRouter(middleware ...Middleware) *Router {
+	return &Router{
+		mux:        http.NewServeMux(),
+		routes:     &routes{},
+		middleware: middleware,
+	}
+}
+
+// Router registers routes on a http.ServeMux with method matching, path prefixes and middleware per group.
+// Patterns follow the http.ServeMux syntax, so path parameters such as /items/{id} are available using r.PathValue.
+// Requests that do not match any route are answered with a 404 or 405 problem response.
+// Routes can be described using RouteOptions, which are used to generate an OpenAPI document using NewOpenApi.
+type Router struct {
+	mux        *http.ServeMux
+	routes     *routes
+	prefix     string
+	middleware []Middleware
+}
+
+// RouteOption describes a route for the OpenAPI document.
+type RouteOption func(r *Route)
+
+// Route is a route registered on a Router, with the metadata describing its operation.
+type Route struct {
+	Method          string
+	Pattern         string
+	OperationID     string
+	Summary         string
+	Description     string
+	Tags            []string
+	Deprecated      bool
+	Query           *zog.StructSchema
+	QueryType       reflect.Type
+	RequestBody     zog.ZSSSerializable
+	RequestBodyType reflect.Type
+	Responses       map[int]RouteResponse
+}
+
+// RouteResponse describes a response of a route. The schema and type are optional.
+type RouteResponse struct {
+	Description string
+	Schema      zog.ZSSSerializable
+	Type        reflect.Type
+}
+
+// routes holds the routes registered by a router and its groups.
+type routes struct {
+	mux  sync.Mutex
+	list []Route
+}
+
+// WithRouteDeprecated marks the operation as deprecated.
+func WithRouteDeprecated() RouteOption {
+	return func(r *Route) {
+		r.Deprecated = true
+	}
+}
+
+// WithRouteDescription sets the description of the operation, which may contain CommonMark.
+func WithRouteDescription(description string) RouteOption {
+	return func(r *Route) {
+		r.Description = description
+	}
+}
+
+// WithRouteOperationID sets the unique identifier of the operation, e.g. getItem.
+func WithRouteOperationID(id string) RouteOption {
+	return func(r *Route) {
+		r.OperationID = id
+	}
+}
+
+// WithRouteQuery describes the query parameters using the zog schema they are bound with and a value of the struct they are bound to,
+// e.g. WithRouteQuery(listSchema, ListQuery{}), see BindQuery. The parameters are named like BindQuery names the fields of the struct.
+func WithRouteQuery(schema *zog.StructSchema, dst any) RouteOption {
+	return func(r *Route) {
+		r.Query = schema
+		r.QueryType = reflect.TypeOf(dst)
+	}
+}
+
+// WithRouteRequestBody describes the JSON request body using the zog schema it is bound with and a value of the type it is bound to,
+// e.g. WithRouteRequestBody(itemSchema, Item{}), see BindJson. Properties are named after the json tags of the fields.
+func WithRouteRequestBody(schema zog.ZSSSerializable, dst any) RouteOption {
+	return func(r *Route) {
+		r.RequestBody = schema
+		r.RequestBodyType = reflect.TypeOf(dst)
+	}
+}
+
+// WithRouteResponse describes the JSON response for the status using a zog schema and a value of the type encoded in the response,
+// e.g. WithRouteResponse(http.StatusOK, "Items", z.Slice(itemSchema), []Item{}). The schema and value are optional.
+func WithRouteResponse(status int, description string, schema zog.ZSSSerializable, v any) RouteOption {
+	return func(r *Route) {
+		if r.Responses == nil {
+			r.Responses = make(map[int]RouteResponse)
+		}
+		r.Responses[status] = RouteResponse{Description: description, Schema: schema, Type: reflect.TypeOf(v)}
+	}
+}
+
+// WithRouteSummary sets the short summary of the operation.
+func WithRouteSummary(summary string) RouteOption {
+	return func(r *Route) {
+		r.Summary = summary
+	}
+}
+
+// WithRouteTags groups the operation under the tags.
+func WithRouteTags(tags ...string) RouteOption {
+	return func(r *Route) {
+		r.Tags = tags
+	}
+}
+
+// Delete registers the handler for DELETE requests matching the pattern.
+func (rt *Router) Delete(pattern string, h HandlerFunc, opts ...RouteOption) {
+	rt.Handle(http.MethodDelete, pattern, h, opts...)
+}
+
+// Get registers the handler for GET and HEAD requests matching the pattern.
+func (rt *Router) Get(pattern string, h HandlerFunc, opts ...RouteOption) {
+	rt.Handle(http.MethodGet, pattern, h, opts...)
+}
+
+// Group returns a Router registering routes below the prefix on the same http.ServeMux.
+// The routes of the group are wrapped in the middleware of the router, followed by the middleware of the group.
+func (rt *Router) Group(prefix string, middleware ...Middleware) *Router {
+	mw := make([]Middleware, 0, len(rt.middleware)+len(middleware))
+	mw = append(mw, rt.middleware...)
+	mw = append(mw, middleware...)
+
+	return &Router{
+		mux:        rt.mux,
+		routes:     rt.routes,
+		prefix:     rt.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: mw,
+	}
+}
+
+// Handle registers the handler for requests with the method matching the pattern. An empty method matches all methods.
+func (rt *Router) Handle(method string, pattern string, h http.Handler, opts ...RouteOption) {
+	r := Route{
+		Method:  method,
+		Pattern: rt.prefix + pattern,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	if method != "" {
+		pattern = method + " " + rt.prefix + pattern
+	} else {
+		pattern = rt.prefix + pattern
+	}
+	rt.mux.Handle(pattern, recordRoute(Chain(h, rt.middleware...)))
+
+	rt.routes.mux.Lock()
+	defer rt.routes.mux.Unlock()
+
+	rt.routes.list = append(rt.routes.list, r)
+}
+
+// Patch registers the handler for PATCH requests matching the pattern.
+func (rt *Router) Patch(pattern string, h HandlerFunc, opts ...RouteOption) {
+	rt.Handle(http.MethodPatch, pattern, h, opts...)
+}
+
+// Post registers the handler for POST requests matching the pattern.
+func (rt *Router) Post(pattern string, h HandlerFunc, opts ...RouteOption) {
+	rt.Handle(http.MethodPost, pattern, h, opts...)
+}
+
+// Put registers the handler for PUT requests matching the pattern.
+func (rt *Router) Put(pattern string, h HandlerFunc, opts ...RouteOption) {
+	rt.Handle(http.MethodPut, pattern, h, opts...)
+}
+
+// Routes returns the routes registered on the router and all routers sharing its http.ServeMux, in the order they were registered.
+func (rt *Router) Routes() []Route {
+	rt.routes.mux.Lock()
+	defer rt.routes.mux.Unlock()
+
+	return slices.Clone(rt.routes.list)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, pattern := rt.mux.Handler(r); pattern == "" {
+		// Let the mux decide between 404 and 405, including the Allow header, and replace its plain text body with a problem
+		sw := &statusWriter{header: w.Header()}
+		h.ServeHTTP(sw, r)
+		if sw.status >= http.StatusBadRequest {
+			WriteProblem(w, r, NewProblem(sw.status, ""))
+			return
+		}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Use adds middleware to the router. It only applies to routes and groups registered afterwards.
+func (rt *Router) Use(middleware ...Middleware) {
+	rt.middleware = append(rt.middleware, middleware...)
+}
+
+// statusWriter records the status code and discards the body of a response.
+type statusWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *statusWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
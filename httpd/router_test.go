@@ -0,0 +1,92 @@
+package httpd
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samber/oops"
+)
+
+// headerMiddleware appends the value to the X-Middleware response header.
+func headerMiddleware(value string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter(headerMiddleware("root"))
+	rt.Get("/health", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.WriteString(w, "ok")
+		return err
+	})
+
+	api := rt.Group("/api/", headerMiddleware("api"))
+	api.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		if r.PathValue("id") == "missing" {
+			return oops.With(StatusKey, http.StatusNotFound).Public("item not found").New("no such item")
+		}
+		_, err := io.WriteString(w, "item "+r.PathValue("id"))
+		return err
+	})
+	api.Post("/items", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("database unavailable")
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		wantStatus     int
+		wantBody       string
+		wantMiddleware string
+		wantAllow      string
+	}{
+		{name: "root route", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK, wantBody: "ok", wantMiddleware: "root"},
+		{name: "group route", method: http.MethodGet, path: "/api/items/42", wantStatus: http.StatusOK, wantBody: "item 42", wantMiddleware: "root,api"},
+		{name: "client error", method: http.MethodGet, path: "/api/items/missing", wantStatus: http.StatusNotFound, wantBody: `"detail":"item not found"`, wantMiddleware: "root,api"},
+		{name: "server error", method: http.MethodPost, path: "/api/items", wantStatus: http.StatusInternalServerError, wantBody: `"status":500`, wantMiddleware: "root,api"},
+		{name: "not found", method: http.MethodGet, path: "/unknown", wantStatus: http.StatusNotFound, wantBody: `"status":404`},
+		{name: "method not allowed", method: http.MethodDelete, path: "/api/items", wantStatus: http.StatusMethodNotAllowed, wantBody: `"status":405`, wantAllow: http.MethodPost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("ServeHTTP() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := strings.Join(w.Header().Values("X-Middleware"), ","); got != tt.wantMiddleware {
+				t.Errorf("ServeHTTP() middleware = %q, want %q", got, tt.wantMiddleware)
+			}
+			if tt.wantAllow != "" && w.Header().Get("Allow") != tt.wantAllow {
+				t.Errorf("ServeHTTP() Allow = %q, want %q", w.Header().Get("Allow"), tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestHandlerFunc_afterWrite(t *testing.T) {
+	h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("failed")
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("ServeHTTP() = %d %q, want 200 %q", w.Code, w.Body.String(), "partial")
+	}
+}
@@ -0,0 +1,150 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultContentSecurityPolicy = "default-src 'self'; base-uri 'self'; frame-ancestors 'none'"
+	DefaultFrameOptions          = "DENY"
+	DefaultHstsMaxAge            = 2 * 365 * 24 * time.Hour
+	DefaultReferrerPolicy        = "strict-origin-when-cross-origin"
+)
+
+// SecurityHeadersOption configures the SecurityHeaders middleware.
+type SecurityHeadersOption func(c *securityHeaders)
+
+type securityHeaders struct {
+	contentSecurityPolicy string
+	frameOptions          string
+	hstsMaxAge            time.Duration
+	hstsSubdomains        bool
+	hstsPreload           bool
+	referrerPolicy        string
+	noSniff               bool
+}
+
+// SecurityHeaders sets response headers instructing browsers to apply security protections:
+//
+//	Strict-Transport-Security   only sent over TLS, max-age DefaultHstsMaxAge
+//	Content-Security-Policy     DefaultContentSecurityPolicy
+//	X-Content-Type-Options      nosniff
+//	Referrer-Policy             DefaultReferrerPolicy
+//	X-Frame-Options             DefaultFrameOptions
+//
+// The headers are set before the handler is called, so the handler can still change them.
+// Use OverrideSecurityHeaders to change some of the headers for a route or group of routes.
+func SecurityHeaders(opts ...SecurityHeadersOption) Middleware {
+	c := &securityHeaders{
+		contentSecurityPolicy: DefaultContentSecurityPolicy,
+		frameOptions:          DefaultFrameOptions,
+		hstsMaxAge:            DefaultHstsMaxAge,
+		referrerPolicy:        DefaultReferrerPolicy,
+		noSniff:               true,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.middleware
+}
+
+// OverrideSecurityHeaders changes the headers set by an outer SecurityHeaders middleware for the routes it is added to,
+// keeping the other headers as configured by the outer middleware. Without an outer SecurityHeaders, it starts from the defaults.
+func OverrideSecurityHeaders(opts ...SecurityHeadersOption) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, ok := r.Context().Value(securityHeadersCtxKey).(*securityHeaders)
+			if !ok {
+				SecurityHeaders(opts...)(next).ServeHTTP(w, r)
+				return
+			}
+
+			override := *c
+			for _, opt := range opts {
+				opt(&override)
+			}
+			override.middleware(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithContentSecurityPolicy sets the Content-Security-Policy header. An empty policy removes the header.
+func WithContentSecurityPolicy(policy string) SecurityHeadersOption {
+	return func(c *securityHeaders) {
+		c.contentSecurityPolicy = policy
+	}
+}
+
+// WithFrameOptions sets the X-Frame-Options header to DENY or SAMEORIGIN. An empty value removes the header.
+func WithFrameOptions(value string) SecurityHeadersOption {
+	return func(c *securityHeaders) {
+		c.frameOptions = value
+	}
+}
+
+// WithHsts sets the Strict-Transport-Security header. A zero max age removes the header.
+func WithHsts(maxAge time.Duration, includeSubdomains bool, preload bool) SecurityHeadersOption {
+	return func(c *securityHeaders) {
+		c.hstsMaxAge = maxAge
+		c.hstsSubdomains = includeSubdomains
+		c.hstsPreload = preload
+	}
+}
+
+// WithNoSniff sets whether the X-Content-Type-Options: nosniff header is sent.
+func WithNoSniff(enabled bool) SecurityHeadersOption {
+	return func(c *securityHeaders) {
+		c.noSniff = enabled
+	}
+}
+
+// WithReferrerPolicy sets the Referrer-Policy header. An empty policy removes the header.
+func WithReferrerPolicy(policy string) SecurityHeadersOption {
+	return func(c *securityHeaders) {
+		c.referrerPolicy = policy
+	}
+}
+
+func (c *securityHeaders) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.apply(w.Header(), r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), securityHeadersCtxKey, c)))
+	})
+}
+
+// apply sets the configured headers and removes the disabled ones, so an override can remove headers set by an outer middleware.
+func (c *securityHeaders) apply(h http.Header, r *http.Request) {
+	set := func(name, value string) {
+		if value == "" {
+			h.Del(name)
+			return
+		}
+		h.Set(name, value)
+	}
+
+	var hsts string
+	if c.hstsMaxAge > 0 && r.TLS != nil {
+		hsts = "max-age=" + strconv.Itoa(int(c.hstsMaxAge.Seconds()))
+		if c.hstsSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if c.hstsPreload {
+			hsts += "; preload"
+		}
+	}
+
+	var noSniff string
+	if c.noSniff {
+		noSniff = "nosniff"
+	}
+
+	set("Strict-Transport-Security", hsts)
+	set("Content-Security-Policy", c.contentSecurityPolicy)
+	set("X-Content-Type-Options", noSniff)
+	set("Referrer-Policy", c.referrerPolicy)
+	set("X-Frame-Options", c.frameOptions)
+}
@@ -0,0 +1,59 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	rt := NewRouter(SecurityHeaders(WithReferrerPolicy("no-referrer")))
+	rt.Handle(http.MethodGet, "/", statusHandler(http.StatusOK))
+	rt.Handle(http.MethodGet, "/embed", OverrideSecurityHeaders(WithFrameOptions("SAMEORIGIN"), WithContentSecurityPolicy(""))(statusHandler(http.StatusOK)))
+	rt.Handle(http.MethodGet, "/preload", OverrideSecurityHeaders(WithHsts(time.Hour, true, true))(statusHandler(http.StatusOK)))
+
+	tests := []struct {
+		name string
+		path string
+		tls  bool
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			path: "/",
+			want: map[string]string{
+				"Strict-Transport-Security": "",
+				"Content-Security-Policy":   DefaultContentSecurityPolicy,
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{name: "tls", path: "/", tls: true, want: map[string]string{"Strict-Transport-Security": "max-age=63072000"}},
+		{
+			name: "override",
+			path: "/embed",
+			want: map[string]string{"Content-Security-Policy": "", "Referrer-Policy": "no-referrer", "X-Frame-Options": "SAMEORIGIN"},
+		},
+		{name: "override hsts", path: "/preload", tls: true, want: map[string]string{"Strict-Transport-Security": "max-age=3600; includeSubDomains; preload"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			for k, v := range tt.want {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("SecurityHeaders() %s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
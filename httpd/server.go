@@ -0,0 +1,444 @@
+package httpd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
+// Option configures a Server.
+type Option func(s *Server)
+
+// NewServer creates a Server for the handler, configured by the supplied options.
+// At least one listener must be configured using WithTcpListener, WithUnixListener or WithListener.
+func NewServer(h http.Handler, opts ...Option) *Server {
+	s := &Server{
+		server:            &http.Server{},
+		handler:           h,
+		shutdownTimeout:   DefaultShutdownTimeout,
+		conns:             make(map[net.Conn]struct{}),
+		tlsReloadInterval: DefaultCertificateReloadInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.log == nil {
+		s.log = slogd.All().DefaultLogger()
+	}
+
+	if s.server.ErrorLog == nil {
+		s.server.ErrorLog = slog.NewLogLogger(s.log.Handler(), slogd.LevelError)
+	}
+
+	connState := s.server.ConnState
+	s.server.ConnState = func(c net.Conn, state http.ConnState) {
+		s.trackConn(c, state)
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+	return s
+}
+
+// Server serves a handler on one or more listeners, which are shut down together when the context passed to Run is cancelled.
+type Server struct {
+	server            *http.Server
+	handler           http.Handler
+	listeners         []listenerConfig
+	log               *slog.Logger
+	preShutdownDelay  time.Duration
+	shutdownHooks     []func(ctx context.Context) error
+	shutdownTimeout   time.Duration
+	socketMode        os.FileMode
+	draining          atomic.Bool
+	forceClosed       atomic.Int64
+	conns             map[net.Conn]struct{}
+	connsMux          sync.Mutex
+	tlsConfig         *tls.Config
+	tlsCertFile       string
+	tlsKeyFile        string
+	tlsCaFile         string
+	tlsClientAuth     *tls.ClientAuthType
+	tlsReloadInterval time.Duration
+}
+
+type listenerConfig struct {
+	network  string
+	address  string
+	listener net.Listener
+}
+
+// WithConnState sets a function that is called when a client connection changes state.
+func WithConnState(f func(net.Conn, http.ConnState)) Option {
+	return func(s *Server) {
+		s.server.ConnState = f
+	}
+}
+
+// WithErrorLog sets the logger for errors accepting connections and unexpected behaviour from handlers.
+// By default, errors are logged to the server logger at error level. Use slogd.GetLogLogger to log to a specific flow.
+func WithErrorLog(l *log.Logger) Option {
+	return func(s *Server) {
+		s.server.ErrorLog = l
+	}
+}
+
+// WithH2c serves HTTP/2 without TLS next to HTTP/1.1, e.g. for internal traffic on unix sockets.
+// Clients must use HTTP/2 with prior knowledge, upgrading an HTTP/1.1 connection to h2c is not supported.
+func WithH2c() Option {
+	return func(s *Server) {
+		if s.server.Protocols == nil {
+			s.server.Protocols = new(http.Protocols)
+			s.server.Protocols.SetHTTP1(true)
+			s.server.Protocols.SetHTTP2(true)
+		}
+		s.server.Protocols.SetUnencryptedHTTP2(true)
+	}
+}
+
+// WithHttp2MaxConcurrentStreams sets the maximum number of concurrent streams a client can open on an HTTP/2 connection.
+func WithHttp2MaxConcurrentStreams(n int) Option {
+	return func(s *Server) {
+		s.http2Config().MaxConcurrentStreams = n
+	}
+}
+
+// WithHttp2ReadIdleTimeout sends a ping on HTTP/2 connections that did not receive any frames for the duration, to detect broken connections.
+func WithHttp2ReadIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.http2Config().SendPingTimeout = d
+	}
+}
+
+// WithIdleTimeout sets the maximum amount of time to wait for the next request when keep-alives are enabled.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.server.IdleTimeout = d
+	}
+}
+
+// WithListener serves on a listener that has already been bound by the caller.
+func WithListener(l net.Listener) Option {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, listenerConfig{network: l.Addr().Network(), address: l.Addr().String(), listener: l})
+	}
+}
+
+// WithLogger sets the logger for the server lifecycle.
+func WithLogger(log *slog.Logger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum number of bytes the server reads parsing the request header.
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *Server) {
+		s.server.MaxHeaderBytes = n
+	}
+}
+
+// WithPreShutdownDelay keeps serving requests for the duration after shutdown has started and before the listeners are closed,
+// giving load balancers time to notice that Draining reports true and stop sending new requests.
+func WithPreShutdownDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.preShutdownDelay = d
+	}
+}
+
+// WithReadHeaderTimeout sets the amount of time allowed to read request headers.
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.server.ReadHeaderTimeout = d
+	}
+}
+
+// WithReadTimeout sets the maximum duration for reading the entire request, including the body.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.server.ReadTimeout = d
+	}
+}
+
+// WithShutdownHook registers a function that is called when the server shuts down, while active connections are drained.
+// It is used to end long-lived streams, such as the Shutdown method of a Broker or WebSocketUpgrader, so they are closed cleanly
+// instead of being cut off when the shutdown timeout expires. The context expires with the shutdown timeout.
+func WithShutdownHook(f func(ctx context.Context) error) Option {
+	return func(s *Server) {
+		s.shutdownHooks = append(s.shutdownHooks, f)
+	}
+}
+
+// WithShutdownTimeout sets the maximum duration to wait for active connections to finish when the server shuts down.
+// Connections that are still active when the timeout expires are closed forcibly.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithTcpListener serves on the listen address and port.
+// If a listener for the address was inherited through socket activation, it is used instead of binding a new one.
+func WithTcpListener(listenAddress string, port int) Option {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, listenerConfig{network: "tcp", address: net.JoinHostPort(listenAddress, strconv.Itoa(port))})
+	}
+}
+
+// WithUnixListener serves on the unix socket.
+// If a listener for the socket path was inherited through socket activation, it is used instead of binding a new one.
+func WithUnixListener(socketPath string) Option {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, listenerConfig{network: "unix", address: socketPath})
+	}
+}
+
+// WithUnixSocketMode sets the permissions of the socket files of unix listeners, e.g. 0660 to allow access to the group of the process.
+func WithUnixSocketMode(mode os.FileMode) Option {
+	return func(s *Server) {
+		s.socketMode = mode
+	}
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of the response.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.server.WriteTimeout = d
+	}
+}
+
+// Run serves on all configured listeners until the context is cancelled, after which the server is shut down gracefully.
+// If any of the listeners fails, all listeners are shut down and the error is returned.
+func (s *Server) Run(ctx context.Context) error {
+	oopsErr := oops.FromContext(ctx).In("httpd").With("listenAddress", s.addresses())
+	if len(s.listeners) == 0 {
+		return oopsErr.New("no listeners configured")
+	}
+
+	// The handler is wrapped on every run, so running the server again does not wrap it twice
+	var err error
+	var reloader *certificateReloader
+	h := s.handler
+	if s.tlsEnabled() {
+		if reloader, err = s.configureTLS(); err != nil {
+			s.log.LogAttrs(ctx, slogd.LevelError, "failed to configure tls", slog.String("error", err.Error()))
+			return oopsErr.Wrap(err)
+		}
+		h = withClientIdentity(h)
+	}
+	s.server.Handler = s.drain(h)
+
+	var listeners []net.Listener
+	if listeners, err = s.listen(ctx); err != nil {
+		s.log.LogAttrs(ctx, slogd.LevelError, "failed to listen on address", slog.String("error", err.Error()))
+		return oopsErr.Wrap(err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithCancel(ctx)
+	defer shutdownCancel()
+
+	// Run goroutine to handle graceful shutdown
+	idleConnectionsClosed := make(chan struct{})
+	go s.shutdown(shutdownCtx, idleConnectionsClosed)
+
+	if reloader != nil {
+		go reloader.run(shutdownCtx, s.log, s.tlsReloadInterval)
+	}
+
+	chErr := make(chan error, len(listeners))
+	for _, l := range listeners {
+		s.log.LogAttrs(ctx, slogd.LevelInfo, "starting http server", slog.String("listenAddress", listenerAddress(l, s.tlsEnabled())))
+		go s.serve(l, chErr)
+	}
+
+	var errs []error
+	for range listeners {
+		if err = <-chErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			// Error starting or closing listener, shut down the other listeners
+			s.log.LogAttrs(ctx, slogd.LevelError, "http server start failed", slog.String("error", err.Error()))
+			errs = append(errs, err)
+			shutdownCancel()
+		}
+	}
+
+	// Keep server running until shutdown has completed
+	<-idleConnectionsClosed
+	if len(errs) > 0 {
+		return oopsErr.Wrap(errors.Join(errs...))
+	}
+	return nil
+}
+
+// Draining reports if the server has started shutting down, which can be used to fail readiness checks during the pre-shutdown delay.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// ForceClosed returns the number of connections that were closed forcibly because they were still active when the shutdown timeout expired.
+func (s *Server) ForceClosed() int {
+	return int(s.forceClosed.Load())
+}
+
+func (s *Server) addresses() string {
+	addresses := make([]string, len(s.listeners))
+	for i, l := range s.listeners {
+		addresses[i] = l.address
+	}
+	return strings.Join(addresses, ",")
+}
+
+func (s *Server) http2Config() *http.HTTP2Config {
+	if s.server.HTTP2 == nil {
+		s.server.HTTP2 = new(http.HTTP2Config)
+	}
+	return s.server.HTTP2
+}
+
+// listen binds all configured listeners. If any of them fails, the listeners bound so far are closed.
+func (s *Server) listen(ctx context.Context) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(s.listeners))
+	for _, config := range s.listeners {
+		if config.listener != nil {
+			listeners = append(listeners, config.listener)
+			continue
+		}
+
+		l, err := listen(ctx, config.network, config.address)
+		if err == nil {
+			if err = setSocketMode(l, s.socketMode); err != nil {
+				_ = l.Close()
+			}
+		}
+		if err != nil {
+			for _, bound := range listeners {
+				_ = bound.Close()
+			}
+			return nil, oops.With("network", config.network).With("address", config.address).Wrap(err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func (s *Server) serve(l net.Listener, chErr chan error) {
+	defer registerListener(l)()
+
+	if s.tlsEnabled() {
+		chErr <- s.server.ServeTLS(l, "", "")
+		return
+	}
+	chErr <- s.server.Serve(l)
+}
+
+func (s *Server) shutdown(ctx context.Context, idleConnectionsClosed chan struct{}) {
+	s.log.LogAttrs(ctx, slogd.LevelTrace, "awaiting shutdown signal for http server", slog.String("listenAddress", s.addresses()))
+
+	// Wait for context to start shutting down the server, or for a new process to take over after a graceful restart
+	select {
+	case <-ctx.Done():
+	case <-currentRestartState().draining():
+	}
+
+	s.log.LogAttrs(ctx, slogd.LevelTrace, "shutdown signal received for http server", slog.String("listenAddress", s.addresses()))
+
+	// Signal clients to close their connections after the current request
+	s.draining.Store(true)
+	s.server.SetKeepAlivesEnabled(false)
+
+	if s.preShutdownDelay > 0 {
+		s.log.LogAttrs(ctx, slogd.LevelDebug, "delaying shutdown for http server", slog.String("listenAddress", s.addresses()), slog.Duration("delay", s.preShutdownDelay))
+		time.Sleep(s.preShutdownDelay)
+	}
+
+	// Stop accepting connections and wait for active connections to finish within the configured shutdown timeout
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer shutdownCancel()
+
+	var hooks sync.WaitGroup
+	for _, hook := range s.shutdownHooks {
+		hooks.Add(1)
+		go func() {
+			defer hooks.Done()
+			if err := hook(shutdownCtx); err != nil {
+				s.log.LogAttrs(ctx, slogd.LevelWarn, "shutdown hook for http server failed", slog.String("listenAddress", s.addresses()), slog.Any("error", err))
+			}
+		}()
+	}
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		active := s.activeConns()
+		if errors.Is(err, context.DeadlineExceeded) {
+			_ = s.server.Close()
+			s.forceClosed.Add(int64(active))
+		}
+		s.log.LogAttrs(ctx, slogd.LevelWarn, "graceful shutdown for http server failed",
+			slog.String("listenAddress", s.addresses()),
+			slog.Int("forceClosed", active),
+			slog.Any("error", err))
+	}
+	hooks.Wait()
+	close(idleConnectionsClosed)
+	s.log.LogAttrs(ctx, slogd.LevelTrace, "shutdown for http server completed", slog.String("listenAddress", s.addresses()))
+}
+
+// drain asks clients to close the connection after the response while the server is shutting down.
+func (s *Server) drain(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() {
+			w.Header().Set("Connection", "close")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// trackConn keeps track of the open connections, so the number of connections closed forcibly on shutdown can be reported.
+func (s *Server) trackConn(c net.Conn, state http.ConnState) {
+	s.connsMux.Lock()
+	defer s.connsMux.Unlock()
+
+	switch state {
+	case http.StateNew:
+		s.conns[c] = struct{}{}
+	case http.StateHijacked, http.StateClosed:
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) activeConns() int {
+	s.connsMux.Lock()
+	defer s.connsMux.Unlock()
+
+	return len(s.conns)
+}
+
+// listenerAddress returns the address of the listener as a URL for logging.
+func listenerAddress(l net.Listener, tls bool) string {
+	switch {
+	case l.Addr().Network() == "unix":
+		return "unix://" + l.Addr().String()
+	case tls:
+		return "https://" + l.Addr().String()
+	default:
+		return "http://" + l.Addr().String()
+	}
+}
@@ -0,0 +1,314 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	errorLog := log.New(io.Discard, "", 0)
+
+	s := NewServer(http.NotFoundHandler(),
+		WithReadTimeout(time.Second),
+		WithReadHeaderTimeout(2*time.Second),
+		WithWriteTimeout(3*time.Second),
+		WithIdleTimeout(4*time.Second),
+		WithMaxHeaderBytes(1024),
+		WithErrorLog(errorLog),
+		WithShutdownTimeout(5*time.Second),
+		WithTcpListener("127.0.0.1", 8080),
+		WithUnixListener("/run/app.sock"))
+
+	if s.server.ReadTimeout != time.Second || s.server.ReadHeaderTimeout != 2*time.Second || s.server.WriteTimeout != 3*time.Second || s.server.IdleTimeout != 4*time.Second {
+		t.Errorf("NewServer() timeouts not applied: %+v", s.server)
+	}
+	if s.server.MaxHeaderBytes != 1024 {
+		t.Errorf("NewServer() MaxHeaderBytes = %d, want 1024", s.server.MaxHeaderBytes)
+	}
+	if s.server.ErrorLog != errorLog {
+		t.Error("NewServer() ErrorLog not applied")
+	}
+	if s.shutdownTimeout != 5*time.Second {
+		t.Errorf("NewServer() shutdownTimeout = %s, want 5s", s.shutdownTimeout)
+	}
+	if got := s.addresses(); got != "127.0.0.1:8080,/run/app.sock" {
+		t.Errorf("NewServer() addresses = %s, want 127.0.0.1:8080,/run/app.sock", got)
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	socketPath := filepath.Join(t.TempDir(), "server.sock")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	s := NewServer(h, WithListener(tcp), WithUnixListener(socketPath), WithShutdownTimeout(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- s.Run(ctx)
+	}()
+
+	tcpClient := &http.Client{Timeout: time.Second}
+	unixClient := newUnixClient(socketPath)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, tcpErr := tcpClient.Get("http://" + tcp.Addr().String())
+		if tcpErr == nil {
+			_ = resp.Body.Close()
+		}
+		if tcpErr == nil && getBody(unixClient) == "ok" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not serve on all listeners")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err = <-chErr:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+}
+
+func TestServer_RunNoListeners(t *testing.T) {
+	if err := NewServer(http.NotFoundHandler()).Run(context.Background()); err == nil {
+		t.Error("Run() without listeners did not return an error")
+	}
+}
+
+func TestServer_RunAgain(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer busy.Close()
+
+	// The depth of the call stack in the handler shows how many times it was wrapped
+	var depth int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		depth = runtime.Callers(0, make([]uintptr, 64))
+	})
+	s := NewServer(h, WithTcpListener("127.0.0.1", busy.Addr().(*net.TCPAddr).Port))
+
+	var depths []int
+	for range 2 {
+		if err = s.Run(context.Background()); err == nil {
+			t.Fatal("Run() on a busy address did not return an error")
+		}
+		s.server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		depths = append(depths, depth)
+	}
+	if depths[0] != depths[1] {
+		t.Errorf("Run() wrapped the handler again, call depth %d after the first run and %d after the second", depths[0], depths[1])
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			close(started)
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	})
+	var hooked atomic.Bool
+	s := NewServer(h,
+		WithListener(tcp),
+		WithPreShutdownDelay(200*time.Millisecond),
+		WithShutdownTimeout(100*time.Millisecond),
+		WithShutdownHook(func(ctx context.Context) error {
+			hooked.Store(true)
+			return nil
+		}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- s.Run(ctx)
+	}()
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	go func() {
+		resp, blockErr := client.Get("http://" + tcp.Addr().String() + "/block")
+		if blockErr == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for !s.Draining() {
+		if time.Now().After(deadline) {
+			t.Fatal("Draining() = false after the context was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Requests are still served during the pre-shutdown delay, asking the client to close the connection
+	resp, err := client.Get("http://" + tcp.Addr().String())
+	if err != nil {
+		t.Fatalf("request during pre-shutdown delay failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if !resp.Close {
+		t.Error("response during pre-shutdown delay did not close the connection")
+	}
+
+	select {
+	case err = <-chErr:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after the shutdown timeout")
+	}
+
+	if !hooked.Load() {
+		t.Error("Run() did not call the shutdown hook")
+	}
+	if got := s.ForceClosed(); got != 1 {
+		t.Errorf("ForceClosed() = %d, want 1", got)
+	}
+}
+
+func TestServer_RunH2c(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "h2c.sock")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	})
+	s := NewServer(h, WithUnixListener(socketPath), WithH2c(), WithHttp2MaxConcurrentStreams(10), WithShutdownTimeout(time.Second))
+	if s.server.HTTP2 == nil || s.server.HTTP2.MaxConcurrentStreams != 10 {
+		t.Errorf("NewServer() HTTP2 = %+v, want MaxConcurrentStreams 10", s.server.HTTP2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	h2c := newUnixClient(socketPath)
+	h2c.Transport.(*http.Transport).Protocols = new(http.Protocols)
+	h2c.Transport.(*http.Transport).Protocols.SetUnencryptedHTTP2(true)
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		want   string
+	}{
+		{name: "http/1.1", client: newUnixClient(socketPath), want: "HTTP/1.1"},
+		{name: "h2c", client: h2c, want: "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			for deadline := time.Now().Add(time.Second); got == "" && time.Now().Before(deadline); {
+				if got = getBody(tt.client); got == "" {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Run() served protocol %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_RunUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket file left behind by a process that did not close its listener
+	stalePath := filepath.Join(dir, "stale.sock")
+	stale, err := net.Listen("unix", stalePath)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	inUsePath := filepath.Join(dir, "inuse.sock")
+	inUse, err := net.Listen("unix", inUsePath)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer inUse.Close()
+
+	filePath := filepath.Join(dir, "file")
+	_ = os.WriteFile(filePath, nil, 0600)
+
+	tests := []struct {
+		name       string
+		socketPath string
+		wantErr    bool
+	}{
+		{name: "new socket", socketPath: filepath.Join(dir, "new.sock")},
+		{name: "stale socket", socketPath: stalePath},
+		{name: "socket in use", socketPath: inUsePath, wantErr: true},
+		{name: "not a socket", socketPath: filePath, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(http.NotFoundHandler(), WithUnixListener(tt.socketPath), WithUnixSocketMode(0660))
+
+			listeners, err := s.listen(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			fi, err := os.Stat(tt.socketPath)
+			if err != nil {
+				t.Fatalf("listen() did not create the socket file: %v", err)
+			}
+			if fi.Mode().Perm() != 0660 {
+				t.Errorf("listen() socket mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0660))
+			}
+
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			if _, err = os.Stat(tt.socketPath); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("closing the listener did not remove the socket file, error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package httpd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	DefaultBrokerBuffer    = 64
+	DefaultBrokerHeartbeat = 15 * time.Second
+	DefaultBrokerHistory   = 100
+	HeaderLastEventID      = "Last-Event-ID"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID identifies the event, so clients can resume the stream after it. If empty, the broker assigns a sequence number.
+	ID string
+	// Event is the event type, which defaults to message on the client.
+	Event string
+	// Data is the payload of the event. Multi-line data is sent as multiple data fields.
+	Data string
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// writeTo writes the event in the text/event-stream format. Line breaks are removed from the ID and event type, so they cannot
+// add fields or events to the stream, and IDs containing NUL are left out, as clients ignore them.
+func (e Event) writeTo(buf *bytes.Buffer) {
+	if id := eventFieldReplacer.Replace(e.ID); id != "" && !strings.ContainsRune(id, 0) {
+		buf.WriteString("id: " + id + "\n")
+	}
+
+	if event := eventFieldReplacer.Replace(e.Event); event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+}
+
+// eventFieldReplacer removes the line breaks from single-line event fields.
+var eventFieldReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+// BrokerOption configures a Broker.
+type BrokerOption func(b *Broker)
+
+// NewBroker creates a Broker publishing server-sent events to subscribed clients.
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		buffer:    DefaultBrokerBuffer,
+		heartbeat: DefaultBrokerHeartbeat,
+		history:   DefaultBrokerHistory,
+		topics:    make(map[string]*brokerTopic),
+		clients:   make(map[*brokerClient]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Broker distributes server-sent events published on topics to the clients subscribed to them.
+//
+// Clients subscribe by requesting the broker with one or more topic query parameters, e.g. /events?topic=jobs&topic=logs.
+// Clients can only subscribe to topics declared using WithBrokerTopics or that events were published on, other topics are not found.
+// The last events of each topic are kept, so clients reconnecting with a Last-Event-ID header receive the events they missed.
+// If the last event ID is no longer kept, all kept events are sent.
+// Clients that do not keep up with the published events are disconnected, after which they reconnect and resume the stream.
+// Register Shutdown using WithShutdownHook, so the streams are ended when the server shuts down.
+type Broker struct {
+	buffer        int
+	heartbeat     time.Duration
+	history       int
+	flow          string
+	shutdownEvent *Event
+
+	mux      sync.Mutex
+	seq      uint64
+	topics   map[string]*brokerTopic
+	clients  map[*brokerClient]struct{}
+	closed   bool
+	handlers sync.WaitGroup
+}
+
+type brokerTopic struct {
+	declared bool
+	history  []brokerEvent
+	clients  map[*brokerClient]struct{}
+}
+
+type brokerEvent struct {
+	seq   uint64
+	event Event
+}
+
+type brokerClient struct {
+	events chan brokerEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// disconnect ends the stream of the client.
+func (c *brokerClient) disconnect() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// WithBrokerBuffer sets the number of events buffered for each client before it is disconnected as a slow consumer.
+func WithBrokerBuffer(n int) BrokerOption {
+	return func(b *Broker) {
+		b.buffer = n
+	}
+}
+
+// WithBrokerFlow logs the subscriptions to the named slogd flow.
+func WithBrokerFlow(name string) BrokerOption {
+	return func(b *Broker) {
+		b.flow = name
+	}
+}
+
+// WithBrokerHeartbeat sets the interval of the comments sent to keep idle streams open through proxies. Zero disables heartbeats.
+func WithBrokerHeartbeat(d time.Duration) BrokerOption {
+	return func(b *Broker) {
+		b.heartbeat = d
+	}
+}
+
+// WithBrokerHistory sets the number of events kept per topic to resume streams with.
+func WithBrokerHistory(n int) BrokerOption {
+	return func(b *Broker) {
+		b.history = n
+	}
+}
+
+// WithBrokerTopics declares the topics clients can subscribe to before any event was published on them.
+func WithBrokerTopics(names ...string) BrokerOption {
+	return func(b *Broker) {
+		for _, name := range names {
+			b.topics[name] = &brokerTopic{declared: true, clients: make(map[*brokerClient]struct{})}
+		}
+	}
+}
+
+// WithBrokerShutdownEvent sets an event that is sent to all clients as the last event when the broker shuts down,
+// e.g. to tell them when to reconnect using its Retry field.
+func WithBrokerShutdownEvent(e Event) BrokerOption {
+	return func(b *Broker) {
+		b.shutdownEvent = &e
+	}
+}
+
+// Publish sends the event to all clients subscribed to the topic. Publishing after Shutdown has no effect.
+func (b *Broker) Publish(topic string, e Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+
+	t, ok := b.topics[topic]
+	if !ok {
+		if b.history <= 0 {
+			// Nobody is subscribed and nothing is kept
+			return
+		}
+		t = &brokerTopic{clients: make(map[*brokerClient]struct{})}
+		b.topics[topic] = t
+	}
+
+	be := brokerEvent{seq: b.seq, event: e}
+	if b.history > 0 {
+		if len(t.history) >= b.history {
+			t.history = slices.Delete(t.history, 0, len(t.history)-b.history+1)
+		}
+		t.history = append(t.history, be)
+	}
+
+	for c := range t.clients {
+		select {
+		case c.events <- be:
+		default:
+			// Slow consumer, the client resumes from the last event it received when it reconnects
+			c.disconnect()
+		}
+	}
+}
+
+// ServeHTTP streams the events of the topics in the query string to the client.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topics := r.URL.Query()["topic"]
+	if len(topics) == 0 {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "no topic requested"))
+		return
+	}
+
+	lastEventID := r.Header.Get(HeaderLastEventID)
+	c, replay, err := b.subscribe(topics, lastEventID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	defer b.unsubscribe(c, topics)
+
+	log := flowLogger(ctx, b.flow)
+	log.LogAttrs(ctx, slogd.LevelDebug, "event stream subscribed",
+		slog.Any("topics", topics),
+		slog.String("lastEventId", lastEventID),
+		slog.String("remoteAddress", r.RemoteAddr))
+
+	// Streams are long-lived, so they must not be cut off by the write timeout of the server
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", ContentTypeEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var buf bytes.Buffer
+	for _, e := range replay {
+		e.event.writeTo(&buf)
+	}
+	if !flushEvents(w, rc, &buf) {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if b.heartbeat > 0 {
+		ticker := time.NewTicker(b.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			b.drain(c, &buf)
+			_ = flushEvents(w, rc, &buf)
+			log.LogAttrs(ctx, slogd.LevelDebug, "event stream closed by server", slog.Any("topics", topics), slog.String("remoteAddress", r.RemoteAddr))
+			return
+		case e := <-c.events:
+			e.event.writeTo(&buf)
+		case <-heartbeat:
+			buf.WriteString(": heartbeat\n\n")
+		}
+
+		if !flushEvents(w, rc, &buf) {
+			return
+		}
+	}
+}
+
+// Shutdown ends all streams after sending the buffered events and the shutdown event, and waits until the clients are disconnected
+// or the context expires.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.mux.Lock()
+	b.closed = true
+	for c := range b.clients {
+		if b.shutdownEvent != nil {
+			select {
+			case c.events <- brokerEvent{event: *b.shutdownEvent}:
+			default:
+			}
+		}
+		c.disconnect()
+	}
+	b.mux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain writes the events that were buffered for the client when it was disconnected.
+func (b *Broker) drain(c *brokerClient, buf *bytes.Buffer) {
+	for {
+		select {
+		case e := <-c.events:
+			e.event.writeTo(buf)
+		default:
+			return
+		}
+	}
+}
+
+// subscribe registers a client for the topics, and returns the events published after the last event ID received by the client.
+func (b *Broker) subscribe(topics []string, lastEventID string) (*brokerClient, []brokerEvent, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.closed {
+		return nil, nil, oops.In("httpd").With(StatusKey, http.StatusServiceUnavailable).Public("server is shutting down").New("server is shutting down")
+	}
+
+	for _, name := range topics {
+		if _, ok := b.topics[name]; !ok {
+			return nil, nil, oops.In("httpd").With(StatusKey, http.StatusNotFound).With("topic", name).Public("unknown topic").New("unknown topic")
+		}
+	}
+
+	c := &brokerClient{
+		events: make(chan brokerEvent, b.buffer),
+		done:   make(chan struct{}),
+	}
+	b.clients[c] = struct{}{}
+	b.handlers.Add(1)
+
+	for _, name := range topics {
+		b.topics[name].clients[c] = struct{}{}
+	}
+
+	if lastEventID == "" {
+		return c, nil, nil
+	}
+
+	// Find the sequence number of the last event received, events published before it are not replayed
+	var after uint64
+	for _, name := range topics {
+		for _, e := range b.topics[name].history {
+			if e.event.ID == lastEventID {
+				after = e.seq
+			}
+		}
+	}
+
+	var replay []brokerEvent
+	for _, name := range topics {
+		for _, e := range b.topics[name].history {
+			if e.seq > after {
+				replay = append(replay, e)
+			}
+		}
+	}
+	slices.SortFunc(replay, func(a, b brokerEvent) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return c, replay, nil
+}
+
+// unsubscribe removes the client from the topics, and removes the undeclared topics that are left without clients and history.
+func (b *Broker) unsubscribe(c *brokerClient, topics []string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, name := range topics {
+		t, ok := b.topics[name]
+		if !ok {
+			continue
+		}
+		delete(t.clients, c)
+		if !t.declared && len(t.clients) == 0 && len(t.history) == 0 {
+			delete(b.topics, name)
+		}
+	}
+	delete(b.clients, c)
+	c.disconnect()
+	b.handlers.Done()
+}
+
+// flushEvents writes the buffered events to the client, and reports if the client is still connected.
+func flushEvents(w http.ResponseWriter, rc *http.ResponseController, buf *bytes.Buffer) bool {
+	defer buf.Reset()
+
+	if buf.Len() > 0 {
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return false
+		}
+	}
+	return rc.Flush() == nil
+}
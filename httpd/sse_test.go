@@ -0,0 +1,162 @@
+package httpd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents reads n events from the stream, skipping comments.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+
+	var events []string
+	var event strings.Builder
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, ":"):
+		case line == "\n":
+			if event.Len() > 0 {
+				events = append(events, event.String())
+				event.Reset()
+			}
+		default:
+			event.WriteString(line)
+		}
+	}
+	return events
+}
+
+func subscribe(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set(HeaderLastEventID, lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentTypeEventStream {
+		t.Fatalf("subscribe status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker(WithBrokerHistory(2), WithBrokerTopics("jobs", "logs"), WithBrokerShutdownEvent(Event{Event: "shutdown", Data: "bye", Retry: time.Second}))
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	resp, r := subscribe(t, srv.URL+"?topic=jobs&topic=logs", "")
+	defer resp.Body.Close()
+
+	// The subscription is registered before the response header is sent
+	b.Publish("jobs", Event{Event: "progress", Data: "10%"})
+	b.Publish("other", Event{Data: "ignored"})
+	b.Publish("logs", Event{ID: "log-1", Data: "line 1\nline 2"})
+
+	want := []string{
+		"id: 1\nevent: progress\ndata: 10%\n",
+		"id: log-1\ndata: line 1\ndata: line 2\n",
+	}
+	if got := readEvents(t, r, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Broker events = %q, want %q", got, want)
+	}
+
+	// Resume after the first event
+	b.Publish("jobs", Event{Data: "20%"})
+	resumed, rr := subscribe(t, srv.URL+"?topic=jobs&topic=logs", "1")
+	defer resumed.Body.Close()
+
+	want = []string{"id: log-1\ndata: line 1\ndata: line 2\n", "id: 4\ndata: 20%\n"}
+	if got := readEvents(t, rr, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Broker resumed events = %q, want %q", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	want = []string{"id: 4\ndata: 20%\n", "event: shutdown\nretry: 1000\ndata: bye\n"}
+	if got := readEvents(t, r, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Broker events on shutdown = %q, want %q", got, want)
+	}
+
+	after, err := http.Get(srv.URL + "?topic=jobs")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	_ = after.Body.Close()
+	if after.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("subscribe after Shutdown() status = %d, want %d", after.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestBroker_noTopic(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewBroker().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestBroker_topics(t *testing.T) {
+	b := NewBroker(WithBrokerHistory(0), WithBrokerTopics("jobs"))
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?topic=jobs&topic=unknown")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("subscribe to unknown topic status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	// Published topics without history are not kept
+	b.Publish("unknown", Event{Data: "ignored"})
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if len(b.topics) != 1 || len(b.topics["jobs"].clients) != 0 {
+		t.Errorf("Broker topics = %v, want only jobs without clients", b.topics)
+	}
+}
+
+func TestEvent_writeTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{name: "data", event: Event{Data: "a\r\nb"}, want: "data: a\ndata: b\n\n"},
+		{name: "id", event: Event{ID: "1\ndata: injected", Data: "a"}, want: "id: 1data: injected\ndata: a\n\n"},
+		{name: "id with nul", event: Event{ID: "1\x00", Data: "a"}, want: "data: a\n\n"},
+		{name: "event", event: Event{Event: "a\r\n\nevent: b", Data: "a"}, want: "event: aevent: b\ndata: a\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.event.writeTo(&buf)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeTo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
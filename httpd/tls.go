@@ -0,0 +1,269 @@
+package httpd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	DefaultCertificateReloadInterval = 30 * time.Second
+)
+
+// DefaultTLSConfig returns a TLS configuration with modern defaults: TLS 1.2 as minimum version, AEAD cipher suites with forward secrecy
+// and X25519/P-256 key exchange. TLS 1.3 cipher suites are not configurable and always enabled.
+func DefaultTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+}
+
+// ClientIdentity describes the verified client certificate of a mutual TLS connection.
+type ClientIdentity struct {
+	Subject        string
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	SerialNumber   string
+	Fingerprint    string // hex encoded SHA-256 fingerprint of the certificate
+	Certificate    *x509.Certificate
+}
+
+// ClientIdentityFromContext returns the verified client identity of the request.
+// It returns false if the connection did not use TLS or the client did not present a verified certificate.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityCtxKey).(ClientIdentity)
+	return identity, ok
+}
+
+func newClientIdentity(cert *x509.Certificate) ClientIdentity {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	uris := make([]string, len(cert.URIs))
+	for i, uri := range cert.URIs {
+		uris[i] = uri.String()
+	}
+
+	return ClientIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           uris,
+		SerialNumber:   cert.SerialNumber.String(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+		Certificate:    cert,
+	}
+}
+
+// withClientIdentity stores the verified client certificate of the connection in the request context.
+func withClientIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), clientIdentityCtxKey, newClientIdentity(r.TLS.VerifiedChains[0][0])))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithCertificateReloadInterval sets how often the certificate, key and client CA files are checked for changes.
+func WithCertificateReloadInterval(d time.Duration) Option {
+	return func(s *Server) {
+		s.tlsReloadInterval = d
+	}
+}
+
+// WithClientAuth sets the policy for client certificate verification. WithClientCA defaults to tls.RequireAndVerifyClientCert.
+func WithClientAuth(auth tls.ClientAuthType) Option {
+	return func(s *Server) {
+		s.tlsClientAuth = &auth
+	}
+}
+
+// WithClientCA enables mutual TLS, verifying client certificates against the CA bundle in caFile.
+// The CA bundle is reloaded when the file changes.
+func WithClientCA(caFile string) Option {
+	return func(s *Server) {
+		s.tlsCaFile = caFile
+	}
+}
+
+// WithTLS serves TLS using the certificate and key files, which are reloaded when they change.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// WithTLSConfig serves TLS using the supplied configuration. If certificate files are configured using WithTLS, they take precedence
+// over the certificates in the configuration. By default, DefaultTLSConfig is used.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
+}
+
+func (s *Server) tlsEnabled() bool {
+	return s.tlsConfig != nil || s.tlsCertFile != "" || s.tlsCaFile != ""
+}
+
+// configureTLS builds the TLS configuration of the server and returns the reloader for the configured files, if any.
+func (s *Server) configureTLS() (*certificateReloader, error) {
+	base := s.tlsConfig
+	if base == nil {
+		base = DefaultTLSConfig()
+	}
+	base = base.Clone()
+
+	// Protocols are negotiated using the configuration returned for each client
+	if len(base.NextProtos) == 0 {
+		base.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if s.tlsCaFile != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if s.tlsClientAuth != nil {
+		base.ClientAuth = *s.tlsClientAuth
+	}
+
+	if s.tlsCertFile == "" && s.tlsCaFile == "" {
+		s.server.TLSConfig = base
+		return nil, nil
+	}
+
+	r := &certificateReloader{
+		certFile: s.tlsCertFile,
+		keyFile:  s.tlsKeyFile,
+		caFile:   s.tlsCaFile,
+		base:     base,
+		modTimes: make(map[string]time.Time),
+	}
+
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+
+	s.server.TLSConfig = &tls.Config{
+		GetConfigForClient: r.getConfigForClient,
+	}
+	return r, nil
+}
+
+// certificateReloader keeps the TLS configuration up to date with the certificate, key and client CA files on disk.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	base     *tls.Config
+	config   *tls.Config
+	modTimes map[string]time.Time
+	mux      sync.RWMutex
+}
+
+func (r *certificateReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.config, nil
+}
+
+// load reads the files if any of them changed since the last load, and returns true if the configuration was updated.
+// If loading fails, the previous configuration is kept.
+func (r *certificateReloader) load() (bool, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	oopsErr := oops.In("httpd").With("certFile", r.certFile).With("keyFile", r.keyFile).With("caFile", r.caFile)
+
+	modTimes := make(map[string]time.Time)
+	changed := r.config == nil
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, oopsErr.Wrap(err)
+		}
+		modTimes[f] = info.ModTime()
+		changed = changed || !info.ModTime().Equal(r.modTimes[f])
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	config := r.base.Clone()
+	if r.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return false, oopsErr.Wrap(err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return false, oopsErr.Wrap(err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, oopsErr.New("no certificates found in client ca file")
+		}
+		config.ClientCAs = pool
+	}
+
+	r.config = config
+	r.modTimes = modTimes
+	return true, nil
+}
+
+// run checks the files for changes at the interval until the context is cancelled.
+func (r *certificateReloader) run(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := r.load(); err != nil {
+				log.LogAttrs(ctx, slogd.LevelWarn, "failed to reload tls certificates", slog.Any("error", err))
+			} else if reloaded {
+				log.LogAttrs(ctx, slogd.LevelInfo, "reloaded tls certificates", slog.String("certFile", r.certFile), slog.String("caFile", r.caFile))
+			}
+		}
+	}
+}
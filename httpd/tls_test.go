@@ -0,0 +1,178 @@
+package httpd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	certFile string
+	keyFile  string
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	tls      tls.Certificate
+}
+
+// newTestCertificate creates a certificate signed by the parent, or a self-signed CA if the parent is nil.
+func newTestCertificate(t *testing.T, dir, name string, serial int64, parent *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	c := &testCertificate{
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+		cert:     cert,
+		key:      key,
+	}
+	_ = os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	if c.tls, err = tls.LoadX509KeyPair(c.certFile, c.keyFile); err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+	return c
+}
+
+func TestServer_RunMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", 1, nil)
+	server := newTestCertificate(t, dir, "server", 2, ca)
+	client := newTestCertificate(t, dir, "client", 3, ca)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := ClientIdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, "no client identity", http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, identity.CommonName)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewServer(h, WithListener(l), WithTLS(server.certFile, server.keyFile), WithClientCA(ca.certFile)).Run(ctx)
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	tests := []struct {
+		name         string
+		certificates []tls.Certificate
+		want         string
+		wantErr      bool
+	}{
+		{name: "client certificate", certificates: []tls.Certificate{client.tls}, want: "client"},
+		{name: "no client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &http.Client{
+				Timeout: 2 * time.Second,
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: tt.certificates},
+				},
+			}
+
+			resp, err := c.Get("https://" + l.Addr().String())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("Get() = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func Test_certificateReloader_load(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", 1, nil)
+	server := newTestCertificate(t, dir, "server", 2, ca)
+
+	r := &certificateReloader{
+		certFile: server.certFile,
+		keyFile:  server.keyFile,
+		base:     DefaultTLSConfig(),
+		modTimes: make(map[string]time.Time),
+	}
+
+	if reloaded, err := r.load(); err != nil || !reloaded {
+		t.Fatalf("load() = %v, %v, want true, nil", reloaded, err)
+	}
+
+	if reloaded, err := r.load(); err != nil || reloaded {
+		t.Fatalf("load() without changes = %v, %v, want false, nil", reloaded, err)
+	}
+
+	// Replace the certificate on disk and make sure the modification time changes
+	renewed := newTestCertificate(t, dir, "server", 4, ca)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(renewed.certFile, future, future)
+
+	if reloaded, err := r.load(); err != nil || !reloaded {
+		t.Fatalf("load() after change = %v, %v, want true, nil", reloaded, err)
+	}
+
+	config, _ := r.getConfigForClient(nil)
+	leaf, _ := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if leaf.SerialNumber.Int64() != 4 {
+		t.Errorf("load() serial = %d, want 4", leaf.SerialNumber.Int64())
+	}
+}
@@ -0,0 +1,64 @@
+package httpd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+// removeStaleSocket removes the socket file at the path if no process is accepting connections on it anymore,
+// which happens when a previous process exited without closing its listener.
+// It returns an error if the socket is still in use, or if the path exists and is not a socket.
+func removeStaleSocket(socketPath string) error {
+	// Abstract sockets do not exist on the filesystem
+	if strings.HasPrefix(socketPath, "@") {
+		return nil
+	}
+
+	oopsErr := oops.In("httpd").With("socketPath", socketPath)
+
+	fi, err := os.Lstat(socketPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return oopsErr.Wrap(err)
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		return oopsErr.New("path exists and is not a socket")
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return oopsErr.New("socket is in use by another process")
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		// Leave the socket alone, binding it reports the actual problem
+		return nil
+	}
+
+	if err = os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return oopsErr.Wrapf(err, "failed to remove stale socket")
+	}
+	return nil
+}
+
+// setSocketMode changes the permissions of the socket file of a unix listener.
+func setSocketMode(l net.Listener, mode os.FileMode) error {
+	if mode == 0 || l.Addr().Network() != "unix" || strings.HasPrefix(l.Addr().String(), "@") {
+		return nil
+	}
+
+	if err := os.Chmod(l.Addr().String(), mode); err != nil {
+		return oops.In("httpd").With("socketPath", l.Addr().String()).With("mode", mode.String()).Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,623 @@
+package httpd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/oops"
+
+	"github.com/jantytgat/go-kit/slogd"
+)
+
+const (
+	DefaultWebSocketCloseTimeout   = 5 * time.Second
+	DefaultWebSocketMaxMessageSize = 1 << 20
+	DefaultWebSocketPingInterval   = 30 * time.Second
+
+	// websocketGuid is appended to the key of the client to compute the accept header, as defined by RFC 6455.
+	websocketGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// maxWebSocketCloseReason is the maximum length of the reason of a close frame, as control frames are limited to 125 bytes.
+	maxWebSocketCloseReason = 123
+)
+
+// WebSocketMessageType is the type of a WebSocket data message.
+type WebSocketMessageType int
+
+const (
+	WebSocketText   WebSocketMessageType = 1
+	WebSocketBinary WebSocketMessageType = 2
+)
+
+const (
+	websocketContinuation = 0
+	websocketClose        = 8
+	websocketPing         = 9
+	websocketPong         = 10
+)
+
+// WebSocket close codes as defined by RFC 6455.
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseUnsupportedData = 1003
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+// WebSocketCloseError is returned by ReadMessage when the connection was closed with a close frame.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return "websocket closed: " + strconv.Itoa(e.Code) + " " + e.Reason
+}
+
+// WebSocketOption configures a WebSocketUpgrader.
+type WebSocketOption func(u *WebSocketUpgrader)
+
+// NewWebSocketUpgrader creates a WebSocketUpgrader. By default, only requests with an Origin header matching the Host header are accepted.
+func NewWebSocketUpgrader(opts ...WebSocketOption) *WebSocketUpgrader {
+	u := &WebSocketUpgrader{
+		closeTimeout:   DefaultWebSocketCloseTimeout,
+		maxMessageSize: DefaultWebSocketMaxMessageSize,
+		pingInterval:   DefaultWebSocketPingInterval,
+		conns:          make(map[*WebSocketConn]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// WebSocketUpgrader upgrades HTTP requests to WebSocket connections, as defined by RFC 6455, and keeps track of them,
+// so they can be closed cleanly when the server shuts down. Register Shutdown using WithShutdownHook.
+type WebSocketUpgrader struct {
+	origins        []string
+	subprotocols   []string
+	closeTimeout   time.Duration
+	maxMessageSize int64
+	pingInterval   time.Duration
+	flow           string
+
+	mux    sync.Mutex
+	conns  map[*WebSocketConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// WithWebSocketCloseTimeout sets how long to wait for the client to answer a close frame before closing the connection.
+// It also limits the time to write a frame to the client.
+func WithWebSocketCloseTimeout(d time.Duration) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.closeTimeout = d
+	}
+}
+
+// WithWebSocketFlow logs the connections to the named slogd flow.
+func WithWebSocketFlow(name string) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.flow = name
+	}
+}
+
+// WithWebSocketMaxMessageSize sets the maximum size of a message received from the client.
+// Larger messages close the connection with WebSocketCloseMessageTooBig.
+func WithWebSocketMaxMessageSize(n int64) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.maxMessageSize = n
+	}
+}
+
+// WithWebSocketOrigins sets the origins allowed to connect, e.g. https://app.example.com. Use * to allow any origin.
+func WithWebSocketOrigins(origins ...string) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.origins = origins
+	}
+}
+
+// WithWebSocketPingInterval sets the interval of the pings sent to the client. Connections that do not receive any frame from the client
+// within twice the interval are closed. Zero disables pings.
+func WithWebSocketPingInterval(d time.Duration) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.pingInterval = d
+	}
+}
+
+// WithWebSocketSubprotocols sets the supported subprotocols in order of preference.
+func WithWebSocketSubprotocols(subprotocols ...string) WebSocketOption {
+	return func(u *WebSocketUpgrader) {
+		u.subprotocols = subprotocols
+	}
+}
+
+// Upgrade completes the WebSocket handshake and takes over the connection. If the handshake fails, a problem response is written
+// and an error is returned. The context of the connection keeps the values of the request context, and is cancelled when the connection closes.
+func (u *WebSocketUpgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*WebSocketConn, error) {
+	oopsErr := oops.FromContext(r.Context()).In("httpd").With("remoteAddress", r.RemoteAddr)
+
+	status, err := u.checkHandshake(r)
+	if err != nil {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		WriteProblem(w, r, NewProblem(status, err.Error()))
+		return nil, oopsErr.With(StatusKey, status).Wrap(err)
+	}
+
+	u.mux.Lock()
+	closed := u.closed
+	u.mux.Unlock()
+	if closed {
+		WriteProblem(w, r, NewProblem(http.StatusServiceUnavailable, "server is shutting down"))
+		return nil, oopsErr.With(StatusKey, http.StatusServiceUnavailable).New("server is shutting down")
+	}
+
+	subprotocol := u.negotiateSubprotocol(r)
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		WriteError(w, r, oopsErr.Wrapf(err, "failed to hijack connection"))
+		return nil, oopsErr.Wrapf(err, "failed to hijack connection")
+	}
+
+	accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGuid))
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " +
+		base64.StdEncoding.EncodeToString(accept[:]) + "\r\n"
+	if subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+
+	// Clear the deadlines set by the server, the connection manages its own deadlines
+	_ = netConn.SetDeadline(time.Time{})
+	if _, err = rw.WriteString(response + "\r\n"); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, oopsErr.Wrapf(err, "failed to complete handshake")
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	c := &WebSocketConn{
+		conn:        netConn,
+		reader:      rw.Reader,
+		upgrader:    u,
+		subprotocol: subprotocol,
+		ctx:         ctx,
+		cancel:      cancel,
+		log:         flowLogger(ctx, u.flow),
+		remoteAddr:  r.RemoteAddr,
+	}
+
+	u.mux.Lock()
+	u.conns[c] = struct{}{}
+	u.wg.Add(1)
+	closed = u.closed
+	u.mux.Unlock()
+
+	// Shutdown started during the handshake
+	if closed {
+		_ = c.Close(WebSocketCloseGoingAway, "server shutting down")
+	}
+
+	c.extendReadDeadline()
+	if u.pingInterval > 0 {
+		go c.ping(u.pingInterval)
+	}
+
+	c.log.LogAttrs(ctx, slogd.LevelDebug, "websocket connected", slog.String("remoteAddress", c.remoteAddr), slog.String("subprotocol", subprotocol))
+	return c, nil
+}
+
+// Shutdown closes all connections with WebSocketCloseGoingAway, and waits until the clients have answered the close frame
+// or the context expires, after which the remaining connections are closed.
+func (u *WebSocketUpgrader) Shutdown(ctx context.Context) error {
+	u.mux.Lock()
+	u.closed = true
+	conns := make([]*WebSocketConn, 0, len(u.conns))
+	for c := range u.conns {
+		conns = append(conns, c)
+	}
+	u.mux.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close(WebSocketCloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range conns {
+			c.closeConn()
+		}
+		return ctx.Err()
+	}
+}
+
+// checkHandshake validates the opening handshake of the client, and returns the status to reject it with.
+func (u *WebSocketUpgrader) checkHandshake(r *http.Request) (int, error) {
+	switch {
+	case r.Method != http.MethodGet:
+		return http.StatusMethodNotAllowed, errors.New("websocket handshake must use GET")
+	case !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket"):
+		return http.StatusUpgradeRequired, errors.New("websocket upgrade required")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		return http.StatusUpgradeRequired, errors.New("unsupported websocket version")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return http.StatusBadRequest, errors.New("invalid websocket key")
+	}
+
+	if !u.originAllowed(r) {
+		return http.StatusForbidden, errors.New("origin not allowed")
+	}
+	return http.StatusOK, nil
+}
+
+func (u *WebSocketUpgrader) negotiateSubprotocol(r *http.Request) string {
+	var requested []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			requested = append(requested, strings.TrimSpace(p))
+		}
+	}
+
+	for _, p := range u.subprotocols {
+		if slices.Contains(requested, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// originAllowed protects against cross-site WebSocket hijacking, as browsers do not apply the same-origin policy to WebSockets.
+func (u *WebSocketUpgrader) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser
+		return true
+	}
+
+	if len(u.origins) == 0 {
+		_, host, found := strings.Cut(origin, "://")
+		return found && strings.EqualFold(host, r.Host)
+	}
+	return slices.Contains(u.origins, "*") || slices.ContainsFunc(u.origins, func(o string) bool {
+		return strings.EqualFold(o, origin)
+	})
+}
+
+func (u *WebSocketUpgrader) remove(c *WebSocketConn) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if _, ok := u.conns[c]; ok {
+		delete(u.conns, c)
+		u.wg.Done()
+	}
+}
+
+// WebSocketConn is a WebSocket connection created by a WebSocketUpgrader. Messages must be read from a single goroutine using ReadMessage,
+// which also handles the ping, pong and close frames of the client. Writing is safe for concurrent use.
+type WebSocketConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	upgrader    *WebSocketUpgrader
+	subprotocol string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	log         *slog.Logger
+	remoteAddr  string
+
+	writeMux  sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+}
+
+// Context returns the context of the connection, which is cancelled when the connection is closed.
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// Subprotocol returns the negotiated subprotocol, or an empty string if none was negotiated.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Close starts the closing handshake by sending a close frame with the code and reason. The connection is closed when the client
+// answers the close frame, or after the close timeout. Reasons longer than 123 bytes are truncated.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	if len(reason) > maxWebSocketCloseReason {
+		n := maxWebSocketCloseReason
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	c.writeMux.Lock()
+	if c.closeSent {
+		c.writeMux.Unlock()
+		return nil
+	}
+	c.closeSent = true
+	err := c.writeFrame(websocketClose, payload)
+	c.writeMux.Unlock()
+
+	if err != nil {
+		c.closeConn()
+		return err
+	}
+
+	// The reader closes the connection when it receives the answer of the client
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.upgrader.closeTimeout))
+	time.AfterFunc(c.upgrader.closeTimeout, c.closeConn)
+	return nil
+}
+
+// ReadMessage returns the next data message of the client. It returns a *WebSocketCloseError when the client closes the connection.
+func (c *WebSocketConn) ReadMessage() (WebSocketMessageType, []byte, error) {
+	var messageType WebSocketMessageType
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			var closeErr *WebSocketCloseError
+			if errors.As(err, &closeErr) {
+				_ = c.Close(closeErr.Code, closeErr.Reason)
+			}
+			c.closeConn()
+			return 0, nil, err
+		}
+		c.extendReadDeadline()
+
+		switch opcode {
+		case websocketPing:
+			if err = c.write(websocketPong, payload); err != nil {
+				c.closeConn()
+				return 0, nil, err
+			}
+			continue
+		case websocketPong:
+			continue
+		case websocketClose:
+			closeErr := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+			if len(payload) == 1 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "invalid close frame")
+			}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+				if !validWebSocketCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+					return 0, nil, c.fail(WebSocketCloseProtocolError, "invalid close frame")
+				}
+			}
+
+			// Answer the close frame of the client, or complete the handshake started by the server
+			code := closeErr.Code
+			if code == WebSocketCloseNoStatus {
+				code = WebSocketCloseNormal
+			}
+			_ = c.Close(code, "")
+			c.closeConn()
+			return 0, nil, closeErr
+		case websocketContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "unexpected continuation frame")
+			}
+		case int(WebSocketText), int(WebSocketBinary):
+			if messageType != 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "expected continuation frame")
+			}
+			messageType = WebSocketMessageType(opcode)
+		default:
+			return 0, nil, c.fail(WebSocketCloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > c.upgrader.maxMessageSize {
+			return 0, nil, c.fail(WebSocketCloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == WebSocketText && !utf8.Valid(message) {
+				return 0, nil, c.fail(WebSocketCloseInvalidPayload, "invalid utf-8 in text message")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a data message to the client.
+func (c *WebSocketConn) WriteMessage(messageType WebSocketMessageType, data []byte) error {
+	return c.write(int(messageType), data)
+}
+
+// closeConn closes the network connection and cancels the context of the connection.
+func (c *WebSocketConn) closeConn() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		_ = c.conn.Close()
+		c.upgrader.remove(c)
+		c.log.LogAttrs(c.ctx, slogd.LevelDebug, "websocket disconnected", slog.String("remoteAddress", c.remoteAddr))
+	})
+}
+
+func (c *WebSocketConn) extendReadDeadline() {
+	if c.upgrader.pingInterval > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(2 * c.upgrader.pingInterval))
+	}
+}
+
+// fail closes the connection because the client violated the protocol.
+// validWebSocketCloseCode reports if the code may be sent in a close frame: the codes defined by RFC 6455 and registered with IANA,
+// except those reserved for reporting a missing or abnormal close, and the codes for libraries and applications.
+func validWebSocketCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < WebSocketCloseNormal || code > 1014:
+		return false
+	default:
+		return code != 1004 && code != WebSocketCloseNoStatus && code != 1006
+	}
+}
+
+func (c *WebSocketConn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	c.closeConn()
+	return oops.In("httpd").With("remoteAddress", c.remoteAddr).With("code", code).New(reason)
+}
+
+func (c *WebSocketConn) ping(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.write(websocketPing, nil); err != nil {
+				c.closeConn()
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads a single frame from the client, which must be masked.
+func (c *WebSocketConn) readFrame() (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(WebSocketCloseProtocolError, "reserved bits set")
+	}
+
+	if !masked {
+		return false, 0, nil, c.fail(WebSocketCloseProtocolError, "client frames must be masked")
+	}
+
+	if opcode >= websocketClose && (!fin || length > 125) {
+		return false, 0, nil, c.fail(WebSocketCloseProtocolError, "invalid control frame")
+	}
+
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.reader, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.reader, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+	}
+
+	if length < 0 || length > c.upgrader.maxMessageSize {
+		return false, 0, nil, c.fail(WebSocketCloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *WebSocketConn) write(opcode int, payload []byte) error {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	return c.writeFrame(opcode, payload)
+}
+
+// writeFrame writes a single unmasked frame. The caller must hold the write lock.
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|byte(opcode))
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	if c.upgrader.closeTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.upgrader.closeTimeout))
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// headerContainsToken reports if the comma separated header contains the token, ignoring case.
+func headerContainsToken(h http.Header, name, token string) bool {
+	return slices.ContainsFunc(headerTokens(h, name), func(t string) bool {
+		return strings.EqualFold(t, token)
+	})
+}
@@ -0,0 +1,220 @@
+package httpd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialWebSocket performs the opening handshake on a raw connection, so the test controls the frames sent to the server.
+func dialWebSocket(t *testing.T, addr string, header string) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"+header+"\r\n")
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+	return conn, r, resp.Status + "|" + resp.Header.Get("Sec-WebSocket-Accept") + "|" + resp.Header.Get("Sec-WebSocket-Protocol")
+}
+
+func writeClientFrame(conn net.Conn, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, _ = conn.Write(frame)
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestWebSocketUpgrader(t *testing.T) {
+	u := NewWebSocketUpgrader(WithWebSocketSubprotocols("v2", "v1"), WithWebSocketPingInterval(0))
+
+	closed := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+
+		for {
+			messageType, message, err := c.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			_ = c.WriteMessage(messageType, append([]byte("echo "), message...))
+		}
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	conn, r, handshake := dialWebSocket(t, addr, "Sec-WebSocket-Protocol: v1, v2\r\n")
+	defer conn.Close()
+
+	// Accept key from the example in RFC 6455
+	if want := "101 Switching Protocols|s3pPLMBiTxaQ9kYGzzhZRbK+xOo=|v2"; handshake != want {
+		t.Fatalf("handshake = %q, want %q", handshake, want)
+	}
+
+	writeClientFrame(conn, 1, []byte("hello"))
+	if opcode, payload := readServerFrame(t, r); opcode != 1 || string(payload) != "echo hello" {
+		t.Errorf("echo frame = %d %q, want text frame %q", opcode, payload, "echo hello")
+	}
+
+	writeClientFrame(conn, websocketPing, []byte("ping"))
+	if opcode, payload := readServerFrame(t, r); opcode != websocketPong || string(payload) != "ping" {
+		t.Errorf("ping answer = %d %q, want pong", opcode, payload)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	chShutdown := make(chan error, 1)
+	go func() {
+		chShutdown <- u.Shutdown(ctx)
+	}()
+
+	opcode, payload := readServerFrame(t, r)
+	if opcode != websocketClose || binary.BigEndian.Uint16(payload) != WebSocketCloseGoingAway {
+		t.Fatalf("shutdown frame = %d %q, want close frame with code %d", opcode, payload, WebSocketCloseGoingAway)
+	}
+	writeClientFrame(conn, websocketClose, payload[:2])
+
+	if err := <-chShutdown; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	var closeErr *WebSocketCloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != WebSocketCloseGoingAway {
+		t.Errorf("ReadMessage() error = %v, want close error with code %d", err, WebSocketCloseGoingAway)
+	}
+}
+
+func TestWebSocketConn_Close(t *testing.T) {
+	u := NewWebSocketUpgrader(WithWebSocketPingInterval(0))
+	reason := strings.Repeat("a", 122) + "é"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := u.Upgrade(w, r); err == nil {
+			_ = c.Close(WebSocketClosePolicyViolation, reason)
+		}
+	}))
+	defer srv.Close()
+
+	conn, r, _ := dialWebSocket(t, strings.TrimPrefix(srv.URL, "http://"), "")
+	defer conn.Close()
+
+	opcode, payload := readServerFrame(t, r)
+	if opcode != websocketClose || binary.BigEndian.Uint16(payload) != WebSocketClosePolicyViolation || string(payload[2:]) != reason[:122] {
+		t.Errorf("close frame = %d %q, want close frame with the reason truncated to %d bytes", opcode, payload, 122)
+	}
+}
+
+func TestWebSocketConn_closeFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  []byte
+		wantCode uint16
+	}{
+		{name: "no status", payload: nil, wantCode: WebSocketCloseNormal},
+		{name: "normal", payload: []byte{0x03, 0xe8}, wantCode: WebSocketCloseNormal},
+		{name: "application", payload: []byte{0x0f, 0xa0, 'o', 'k'}, wantCode: 4000},
+		{name: "one byte", payload: []byte{0x03}, wantCode: WebSocketCloseProtocolError},
+		{name: "below 1000", payload: []byte{0x03, 0xe7}, wantCode: WebSocketCloseProtocolError},
+		{name: "no status code", payload: []byte{0x03, 0xed}, wantCode: WebSocketCloseProtocolError},
+		{name: "abnormal", payload: []byte{0x03, 0xee}, wantCode: WebSocketCloseProtocolError},
+		{name: "tls handshake", payload: []byte{0x03, 0xf7}, wantCode: WebSocketCloseProtocolError},
+		{name: "invalid reason", payload: []byte{0x03, 0xe8, 0xff}, wantCode: WebSocketCloseProtocolError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewWebSocketUpgrader(WithWebSocketPingInterval(0))
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c, err := u.Upgrade(w, r); err == nil {
+					_, _, _ = c.ReadMessage()
+				}
+			}))
+			defer srv.Close()
+
+			conn, r, _ := dialWebSocket(t, strings.TrimPrefix(srv.URL, "http://"), "")
+			defer conn.Close()
+
+			writeClientFrame(conn, websocketClose, tt.payload)
+			if opcode, payload := readServerFrame(t, r); opcode != websocketClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != tt.wantCode {
+				t.Errorf("close answer = %d %q, want close frame with code %d", opcode, payload, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebSocketUpgrader_handshake(t *testing.T) {
+	u := NewWebSocketUpgrader()
+
+	tests := []struct {
+		name       string
+		method     string
+		upgrade    bool
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "not an upgrade", method: http.MethodGet, wantStatus: http.StatusUpgradeRequired},
+		{name: "post", method: http.MethodPost, upgrade: true, wantStatus: http.StatusMethodNotAllowed},
+		{name: "invalid key", method: http.MethodGet, upgrade: true, header: map[string]string{"Sec-WebSocket-Key": "short"}, wantStatus: http.StatusBadRequest},
+		{name: "cross origin", method: http.MethodGet, upgrade: true, header: map[string]string{"Origin": "https://evil.example.com"}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://app.example.com/", nil)
+			if tt.upgrade {
+				r.Header.Set("Connection", "Upgrade")
+				r.Header.Set("Upgrade", "websocket")
+				r.Header.Set("Sec-WebSocket-Version", "13")
+				r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			}
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			if _, err := u.Upgrade(w, r); err == nil {
+				t.Fatal("Upgrade() error = nil")
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("Upgrade() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
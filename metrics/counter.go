@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sync/atomic"
+)
+
+func newCounter(d *desc) *Counter {
+	return &Counter{
+		family: newFamily(d, func() *atomicFloat { return new(atomicFloat) }),
+	}
+}
+
+// Counter is a monotonically increasing value per combination of label values.
+type Counter struct {
+	family *family[*atomicFloat]
+}
+
+// Add increases the counter for the label values. Negative values are ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.family.get(labelValues).add(v)
+}
+
+// Inc increases the counter for the label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current value of the counter for the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.family.get(labelValues).load()
+}
+
+func (c *Counter) descriptor() *desc {
+	return c.family.desc
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	for _, s := range c.family.sorted() {
+		c.family.writeSample(w, c.family.name, s.values, "", "", s.value.load())
+	}
+}
+
+// atomicFloat is a float64 that can be updated concurrently.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
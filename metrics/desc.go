@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func newDesc(name, help, kind string, labels []string) *desc {
+	if !nameRegex.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+
+	for _, l := range labels {
+		if !labelRegex.MatchString(l) || strings.HasPrefix(l, "__") || (kind == "histogram" && l == "le") {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", l, name))
+		}
+	}
+
+	return &desc{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+	}
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	if d.help != "" {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n", d.name, helpEscaper.Replace(d.help))
+	}
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// writeSample writes a sample line for the metric name with the label values and optional extra label.
+func (d *desc) writeSample(w *bufio.Writer, name string, values []string, extraLabel, extraValue string, v float64) {
+	_, _ = w.WriteString(name)
+
+	if len(values) > 0 || extraLabel != "" {
+		_ = w.WriteByte('{')
+		for i, l := range d.labels {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, `%s="%s"`, l, labelEscaper.Replace(values[i]))
+		}
+
+		if extraLabel != "" {
+			if len(values) > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// family holds the series of a metric by label values.
+type family[T any] struct {
+	*desc
+	series map[string]*series[T]
+	create func() T
+	mux    sync.RWMutex
+}
+
+type series[T any] struct {
+	values []string
+	value  T
+}
+
+func newFamily[T any](d *desc, create func() T) *family[T] {
+	return &family[T]{
+		desc:   d,
+		series: make(map[string]*series[T]),
+		create: create,
+	}
+}
+
+func (f *family[T]) descriptor() *desc {
+	return f.desc
+}
+
+// get returns the series for the label values, creating it if needed.
+func (f *family[T]) get(values []string) T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mux.RLock()
+	s, ok := f.series[key]
+	f.mux.RUnlock()
+	if ok {
+		return s.value
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if s, ok = f.series[key]; !ok {
+		s = &series[T]{values: append([]string(nil), values...), value: f.create()}
+		f.series[key] = s
+	}
+	return s.value
+}
+
+// sorted returns all series sorted by their label values.
+func (f *family[T]) sorted() []*series[T] {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	all := make([]*series[T], 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+	return all
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bufio"
+)
+
+func newGauge(d *desc) *Gauge {
+	return &Gauge{
+		family: newFamily(d, func() *atomicFloat { return new(atomicFloat) }),
+	}
+}
+
+// Gauge is a value that can go up and down per combination of label values.
+type Gauge struct {
+	family *family[*atomicFloat]
+}
+
+// Add adds v to the gauge for the label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.family.get(labelValues).add(v)
+}
+
+// Dec decreases the gauge for the label values by one.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Inc increases the gauge for the label values by one.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Set sets the gauge for the label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.family.get(labelValues).store(v)
+}
+
+// Value returns the current value of the gauge for the label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.family.get(labelValues).load()
+}
+
+func (g *Gauge) descriptor() *desc {
+	return g.family.desc
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	for _, s := range g.family.sorted() {
+		g.family.writeSample(w, g.family.name, s.values, "", "", s.value.load())
+	}
+}
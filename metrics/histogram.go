@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"sync"
+)
+
+var (
+	// DefaultBuckets are suited for request latencies in seconds, from 5ms to 10s.
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+func newHistogram(d *desc, buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		family: newFamily(d, func() *histogramValue {
+			return &histogramValue{counts: make([]uint64, len(buckets))}
+		}),
+	}
+}
+
+// Histogram counts observations in cumulative buckets per combination of label values.
+type Histogram struct {
+	buckets []float64
+	family  *family[*histogramValue]
+}
+
+// Observe adds an observation for the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	hv := h.family.get(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	hv.mux.Lock()
+	defer hv.mux.Unlock()
+
+	if i < len(hv.counts) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// Count returns the number of observations for the label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	hv := h.family.get(labelValues)
+
+	hv.mux.Lock()
+	defer hv.mux.Unlock()
+
+	return hv.count
+}
+
+func (h *Histogram) descriptor() *desc {
+	return h.family.desc
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	for _, s := range h.family.sorted() {
+		s.value.mux.Lock()
+		counts := append([]uint64(nil), s.value.counts...)
+		count, sum := s.value.count, s.value.sum
+		s.value.mux.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			h.family.writeSample(w, h.family.name+"_bucket", s.values, "le", formatFloat(upper), float64(cumulative))
+		}
+		h.family.writeSample(w, h.family.name+"_bucket", s.values, "le", formatFloat(math.Inf(1)), float64(count))
+		h.family.writeSample(w, h.family.name+"_sum", s.values, "", "", sum)
+		h.family.writeSample(w, h.family.name+"_count", s.values, "", "", float64(count))
+	}
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	mux    sync.Mutex
+}
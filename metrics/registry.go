@@ -0,0 +1,122 @@
+// Package metrics provides a lightweight registry of counters, gauges and histograms, exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+)
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	defaultRegistry = NewRegistry()
+	labelRegex      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	nameRegex       = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+)
+
+// Default returns the registry used by the instrumentation of the kit.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Handler serves the metrics of the default registry in the text exposition format.
+func Handler() http.Handler {
+	return defaultRegistry.Handler()
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// Registry holds metrics by name.
+// Registering a metric that already exists returns the existing metric, so packages can register their metrics independently.
+// Registering an invalid name or label, or an existing name with a different type or labels, is a programming error and panics.
+type Registry struct {
+	collectors map[string]collector
+	mux        sync.Mutex
+}
+
+type collector interface {
+	descriptor() *desc
+	write(w *bufio.Writer)
+}
+
+// Counter returns the counter with the name, registering it if needed.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return register(r, newDesc(name, help, "counter", labels), newCounter)
+}
+
+// Gauge returns the gauge with the name, registering it if needed.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return register(r, newDesc(name, help, "gauge", labels), newGauge)
+}
+
+// Handler serves the metrics of the registry in the text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.Write(w)
+	})
+}
+
+// Histogram returns the histogram with the name, registering it if needed. If buckets is empty, DefaultBuckets are used.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	return register(r, newDesc(name, help, "histogram", labels), func(d *desc) *Histogram {
+		return newHistogram(d, buckets)
+	})
+}
+
+// Write writes all metrics in the text exposition format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mux.Lock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mux.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].descriptor().name < collectors[j].descriptor().name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.descriptor().writeHeader(bw)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+func register[T collector](r *Registry, d *desc, create func(d *desc) T) T {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if existing, ok := r.collectors[d.name]; ok {
+		c, ok := existing.(T)
+		if !ok || !slices.Equal(existing.descriptor().labels, d.labels) {
+			panic(fmt.Sprintf("metrics: %s already registered with a different type or labels", d.name))
+		}
+		return c
+	}
+
+	c := create(d)
+	r.collectors[d.name] = c
+	return c
+}
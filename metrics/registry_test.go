@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.Counter("requests_total", "Total requests.", "method", "status")
+	requests.Inc("GET", "200")
+	requests.Add(2, "GET", "200")
+	requests.Inc("POST", `5"0\0`)
+	requests.Add(-1, "POST", `5"0\0`)
+
+	inFlight := r.Gauge("in_flight", "Requests\nin flight.")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+
+	latency := r.Histogram("latency_seconds", "", []float64{1, 0.1}, "route")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.5, "/")
+	latency.Observe(5, "/")
+
+	want := `# HELP in_flight Requests\nin flight.
+# TYPE in_flight gauge
+in_flight 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 1
+latency_seconds_bucket{route="/",le="1"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 3
+latency_seconds_sum{route="/"} 5.55
+latency_seconds_count{route="/"} 3
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 3
+requests_total{method="POST",status="5\"0\\0"} 1
+`
+
+	buf := new(bytes.Buffer)
+	if err := r.Write(buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("Write() = \n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRegistry_register(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(r *Registry)
+		wantPanic bool
+	}{
+		{name: "same metric", register: func(r *Registry) { r.Counter("total", "", "a") }},
+		{name: "different type", register: func(r *Registry) { r.Gauge("total", "", "a") }, wantPanic: true},
+		{name: "different labels", register: func(r *Registry) { r.Counter("total", "", "b") }, wantPanic: true},
+		{name: "invalid name", register: func(r *Registry) { r.Counter("1total", "") }, wantPanic: true},
+		{name: "invalid label", register: func(r *Registry) { r.Counter("other_total", "", "a-b") }, wantPanic: true},
+		{name: "reserved histogram label", register: func(r *Registry) { r.Histogram("duration", "", nil, "le") }, wantPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Counter("total", "", "a")
+
+			defer func() {
+				if rec := recover(); (rec != nil) != tt.wantPanic {
+					t.Errorf("register() panic = %v, wantPanic %v", rec, tt.wantPanic)
+				}
+			}()
+			tt.register(r)
+		})
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("total", "").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Header().Get("Content-Type") != ContentType || w.Body.String() != "# TYPE total counter\ntotal 1\n" {
+		t.Errorf("Handler() = %q, %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
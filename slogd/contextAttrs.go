@@ -0,0 +1,62 @@
+package slogd
+
+import (
+	"context"
+	"log/slog"
+)
+
+type attrsContextKey struct{}
+
+var attrsCtxKey = attrsContextKey{}
+
+// AttrsFromContext returns the attributes stored in the context using WithAttrs.
+func AttrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(attrsCtxKey).([]slog.Attr)
+	return attrs
+}
+
+// WithAttrs returns a context carrying the attributes, which are added to every record logged with that context by the loggers of a Flow.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent := AttrsFromContext(ctx)
+
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	merged = append(merged, parent...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsCtxKey, merged)
+}
+
+func newContextAttrsHandler(h slog.Handler) slog.Handler {
+	if h == nil {
+		return nil
+	}
+	return &contextAttrsHandler{handler: h}
+}
+
+// contextAttrsHandler adds the attributes stored in the context of a record before passing it on to the wrapped handler.
+type contextAttrsHandler struct {
+	handler slog.Handler
+}
+
+func (h *contextAttrsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *contextAttrsHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := AttrsFromContext(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *contextAttrsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextAttrsHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *contextAttrsHandler) WithGroup(name string) slog.Handler {
+	return &contextAttrsHandler{handler: h.handler.WithGroup(name)}
+}
@@ -1,6 +1,7 @@
 package slogd
 
 import (
+	"log"
 	"log/slog"
 	"sort"
 	"sync"
@@ -80,6 +81,21 @@ func (l *Flow) getSlogHandlers() []slog.Handler {
 	return convertHandlerToSlogHandler(l.getHandlers())
 }
 
+// Handlers returns the handlers of the flow, sorted by name.
+func (f *Flow) Handlers() []*Handler {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	handlers := f.getHandlers()
+	sort.Sort(NameHandlerSorter(handlers))
+	return handlers
+}
+
+// handler builds the handler of the flow, adding the attributes stored in the context and counting records by level.
+func (f *Flow) handler() slog.Handler {
+	return newMetricsHandler(f.name, newContextAttrsHandler(f.build()))
+}
+
 func (f *Flow) Logger() *slog.Logger {
 	f.mux.Lock()
 	defer f.mux.Unlock()
@@ -88,10 +104,20 @@ func (f *Flow) Logger() *slog.Logger {
 		return f.logger
 	}
 
-	f.logger = slog.New(f.build())
+	f.logger = slog.New(f.handler())
 	return f.logger
 }
 
+// LogLogger returns a *log.Logger writing to the flow at the supplied level, for use with APIs that require the standard logger,
+// such as http.Server.ErrorLog.
+func (f *Flow) LogLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(f.Logger().Handler(), level)
+}
+
+func (f *Flow) Name() string {
+	return f.name
+}
+
 func (f *Flow) SetLevel(level slog.Level) {
 	f.mux.Lock()
 	defer f.mux.Unlock()
@@ -100,7 +126,7 @@ func (f *Flow) SetLevel(level slog.Level) {
 		h.SetLevel(level)
 	}
 
-	f.logger = slog.New(f.build())
+	f.logger = slog.New(f.handler())
 }
 
 func (l *Flow) WithHandler(name string, handler *Handler) *Flow {
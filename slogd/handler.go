@@ -77,6 +77,17 @@ func (h *Handler) HandlerOptions() *HandlerOptions {
 	return h.handlerOptions
 }
 
+// Level returns the minimum level of the handler. It returns false if the level of the handler is not configurable.
+func (h *Handler) Level() (slog.Level, bool) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.handlerOptions == nil {
+		return 0, false
+	}
+	return h.handlerOptions.Level(), true
+}
+
 func (h *Handler) Name() string {
 	return h.name
 }
@@ -106,5 +117,8 @@ func (h *Handler) SetLevel(level slog.Level) {
 	h.mux.Lock()
 	defer h.mux.Unlock()
 
+	if h.handlerOptions == nil {
+		return
+	}
 	h.handlerOptions.SetLevel(level)
 }
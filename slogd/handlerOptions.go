@@ -55,6 +55,13 @@ func (h *HandlerOptions) HandlerOptions() *slog.HandlerOptions {
 	}
 }
 
+func (h *HandlerOptions) Level() slog.Level {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	return h.levelVar.Level()
+}
+
 func (h *HandlerOptions) SetLevel(level slog.Level) {
 	h.mux.Lock()
 	defer h.mux.Unlock()
@@ -35,6 +35,16 @@ func GetLevelFromString(l string) slog.Level {
 	return LevelDefault
 }
 
+// LookupLevel returns the level with the name, and false if the name is unknown.
+func LookupLevel(name string) (slog.Level, bool) {
+	for k, v := range levelNames {
+		if strings.ToUpper(name) == v {
+			return k.Level(), true
+		}
+	}
+	return LevelDefault, false
+}
+
 func GetLevelName(l slog.Level) string {
 	for k, v := range levelNames {
 		if k == l {
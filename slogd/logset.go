@@ -2,6 +2,7 @@ package slogd
 
 import (
 	"log/slog"
+	"sort"
 	"sync"
 )
 
@@ -28,6 +29,39 @@ func (l *LogSet) DefaultLogger() *slog.Logger {
 	return l.flows[l.defaultFlow].Logger()
 }
 
+// DefaultFlowName returns the name of the default flow.
+func (l *LogSet) DefaultFlowName() string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.defaultFlow
+}
+
+// Flow returns the named flow.
+func (l *LogSet) Flow(name string) (*Flow, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	flow, ok := l.flows[name]
+	return flow, ok
+}
+
+// Flows returns all flows, sorted by name.
+func (l *LogSet) Flows() []*Flow {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	flows := make([]*Flow, 0, len(l.flows))
+	for _, flow := range l.flows {
+		flows = append(flows, flow)
+	}
+
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].name < flows[j].name
+	})
+	return flows
+}
+
 func (l *LogSet) Logger(name string) *slog.Logger {
 	l.mux.Lock()
 	defer l.mux.Unlock()
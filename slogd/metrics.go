@@ -0,0 +1,42 @@
+package slogd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jantytgat/go-kit/metrics"
+)
+
+var (
+	recordsTotal = metrics.Default().Counter("slogd_records_total", "Total number of log records handled by slogd flows.", "flow", "level")
+)
+
+func newMetricsHandler(flow string, h slog.Handler) slog.Handler {
+	if h == nil {
+		return nil
+	}
+	return &metricsHandler{flow: flow, handler: h}
+}
+
+// metricsHandler counts the records handled by a flow by level.
+type metricsHandler struct {
+	flow    string
+	handler slog.Handler
+}
+
+func (h *metricsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *metricsHandler) Handle(ctx context.Context, r slog.Record) error {
+	recordsTotal.Inc(h.flow, GetLevelName(r.Level))
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *metricsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &metricsHandler{flow: h.flow, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *metricsHandler) WithGroup(name string) slog.Handler {
+	return &metricsHandler{flow: h.flow, handler: h.handler.WithGroup(name)}
+}
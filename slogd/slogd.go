@@ -2,6 +2,7 @@ package slogd
 
 import (
 	"context"
+	"log"
 	"log/slog"
 	"sync"
 )
@@ -76,6 +77,10 @@ func GetLogger(name string) *slog.Logger {
 	return GetFlow(name).Logger()
 }
 
+func GetLogLogger(name string, level slog.Level) *log.Logger {
+	return GetFlow(name).LogLogger(level)
+}
+
 func SetLevel(flow string, level slog.Level) {
 	GetFlow(flow).SetLevel(level)
 }
@@ -0,0 +1,205 @@
+package slogd
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTeeSize = 1000
+)
+
+// NewTee creates a Tee keeping the last size records. If size is not positive, DefaultTeeSize is used.
+func NewTee(size int) *Tee {
+	if size <= 0 {
+		size = DefaultTeeSize
+	}
+
+	return &Tee{
+		ring:        make([]TeeRecord, 0, size),
+		size:        size,
+		subscribers: make(map[chan TeeRecord]struct{}),
+	}
+}
+
+// Tee keeps a bounded buffer of recent log records and passes new records on to its subscribers, to inspect the logs of a running process.
+// Records are added by the handlers created with NewTeeHandler, which can be added to any number of flows.
+type Tee struct {
+	mux         sync.Mutex
+	ring        []TeeRecord
+	size        int
+	next        int
+	seq         uint64
+	subscribers map[chan TeeRecord]struct{}
+}
+
+// TeeRecord is a log record kept by a Tee. Attributes in groups are keyed by their dotted path, e.g. request.method.
+type TeeRecord struct {
+	Seq     uint64
+	Flow    string
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   map[string]any
+}
+
+// Records returns the records in the buffer, oldest first.
+func (t *Tee) Records() []TeeRecord {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.records()
+}
+
+// Subscribe returns the records in the buffer, and a channel receiving the records added after them until the context is done,
+// after which the channel is closed. Records are dropped if the subscriber does not keep up with the buffer size of the channel.
+func (t *Tee) Subscribe(ctx context.Context, buffer int) ([]TeeRecord, <-chan TeeRecord) {
+	ch := make(chan TeeRecord, buffer)
+
+	t.mux.Lock()
+	t.subscribers[ch] = struct{}{}
+	records := t.records()
+	t.mux.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		t.mux.Lock()
+		defer t.mux.Unlock()
+
+		delete(t.subscribers, ch)
+		close(ch)
+	}()
+	return records, ch
+}
+
+func (t *Tee) add(r TeeRecord) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.seq++
+	r.Seq = t.seq
+
+	if len(t.ring) < t.size {
+		t.ring = append(t.ring, r)
+	} else {
+		t.ring[t.next] = r
+	}
+	t.next = (t.next + 1) % t.size
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
+
+// records returns the records in the buffer, oldest first. The caller must hold the lock.
+func (t *Tee) records() []TeeRecord {
+	if len(t.ring) < t.size {
+		return slices.Clone(t.ring)
+	}
+	return append(slices.Clone(t.ring[t.next:]), t.ring[:t.next]...)
+}
+
+// NewTeeHandler creates a handler adding the records of the flow to the tee.
+func NewTeeHandler(name string, tee *Tee, flow string, level slog.Level) *Handler {
+	opts := NewDefaultHandlerOptions(level, false)
+	return &Handler{
+		name:           name,
+		handler:        &teeHandler{tee: tee, flow: flow, level: opts.HandlerOptions().Level},
+		handlerOptions: opts,
+	}
+}
+
+// teeHandler converts records to TeeRecords, resolving the attributes and groups added to the logger.
+type teeHandler struct {
+	tee    *Tee
+	flow   string
+	level  slog.Leveler
+	attrs  map[string]any
+	prefix string
+}
+
+func (h *teeHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *teeHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		addTeeAttr(attrs, h.prefix, a)
+		return true
+	})
+
+	h.tee.add(TeeRecord{
+		Flow:    h.flow,
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   attrs,
+	})
+	return nil
+}
+
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := h.clone()
+	for _, a := range attrs {
+		addTeeAttr(c.attrs, c.prefix, a)
+	}
+	return c
+}
+
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	c := h.clone()
+	c.prefix = h.prefix + name + "."
+	return c
+}
+
+func (h *teeHandler) clone() *teeHandler {
+	attrs := make(map[string]any, len(h.attrs))
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+	return &teeHandler{tee: h.tee, flow: h.flow, level: h.level, attrs: attrs, prefix: h.prefix}
+}
+
+// addTeeAttr adds the resolved attribute to the map, flattening groups into dotted keys.
+func addTeeAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addTeeAttr(attrs, groupPrefix, ga)
+		}
+		return
+	}
+
+	if a.Key == "" {
+		return
+	}
+
+	switch value := v.Any().(type) {
+	case error:
+		attrs[prefix+a.Key] = value.Error()
+	case time.Duration:
+		attrs[prefix+a.Key] = value.String()
+	default:
+		attrs[prefix+a.Key] = value
+	}
+}